@@ -0,0 +1,140 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"os"
+	"unsafe"
+)
+
+// - MARK: aligned section.
+
+const (
+	cAlignMax = 1 << 16
+)
+
+// GetAligned returns a buffer of at least `size`
+// bytes whose first byte is aligned to `align`,
+// a power of two up to 64 KiB. Aligned buffers
+// are kept in their own classes and must be
+// returned with `ReleaseAligned`.
+func (lfp *LFPool) GetAligned(size int, align int) []byte {
+	child, err := lfp.alignedPool(align)
+	if err != nil {
+		panic(err)
+	}
+	if child == nil {
+		panic("core(pool): invalid alignment")
+	}
+	chunk := child.getChunk(size)
+	return chunk[:size]
+}
+
+// ReleaseAligned puts back a buffer returned by
+// `GetAligned` with the same `align`. Buffers
+// that are not aligned are dropped.
+func (lfp *LFPool) ReleaseAligned(chunk []byte, align int) {
+	v, ok := lfp.aligned.Load(align)
+	if !ok || cap(chunk) == 0 {
+		return
+	}
+	if uintptr(unsafe.Pointer(&chunk[:1][0]))&uintptr(align-1) != 0 {
+		return
+	}
+	v.(*LFPool).releaseChunk(chunk)
+}
+
+// alignedPool returns the pool serving `align`,
+// creating it on first use. It returns nil for
+// invalid alignments and an error when the
+// pool cannot be created.
+func (lfp *LFPool) alignedPool(align int) (*LFPool, error) {
+	if v, ok := lfp.aligned.Load(align); ok {
+		return v.(*LFPool), nil
+	}
+	if !isPow2(align) || align > cAlignMax || align > lfp.opts.MaxSize {
+		return nil, nil
+	}
+	lfp.amu.Lock()
+	defer lfp.amu.Unlock()
+	if v, ok := lfp.aligned.Load(align); ok {
+		return v.(*LFPool), nil
+	}
+	var o Options = lfp.opts
+	o.SlabSize, o.SlabMaxSize = 0, 0
+	if align > os.Getpagesize() {
+		o.OffHeapSize = 0
+	}
+	if o.MinSize < align {
+		o.MinSize = align
+	}
+	o.Classes = nil
+	for _, size := range lfp.opts.Classes {
+		if size >= o.MinSize {
+			o.Classes = append(o.Classes, size)
+		}
+	}
+	o.ProfileName = ""
+	// NOTE
+	// . the layout restarts from `align`, caps
+	//   of classes the child lacks are dropped.
+	ct, err := newClassTable(o.Layout.Classes(o.MinSize, o.MaxSize), o.Classes, o.MinSize, o.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	o.ClassRetained = nil
+	for size, count := range lfp.opts.ClassRetained {
+		if size >= o.MinSize && ct.size(ct.index(size)) == size {
+			if o.ClassRetained == nil {
+				o.ClassRetained = make(map[int]int)
+			}
+			o.ClassRetained[size] = count
+		}
+	}
+	child, err := NewLFPoolWithOptions(WithOptions(o))
+	if err != nil {
+		return nil, err
+	}
+	child.align = align
+	child.profile = lfp.profile
+	lfp.aligned.Store(align, child)
+	return child, nil
+}
+
+// eachAligned calls `fn` with every aligned pool.
+func (lfp *LFPool) eachAligned(fn func(*LFPool)) {
+	lfp.aligned.Range(func(_, v interface{}) bool {
+		fn(v.(*LFPool))
+		return true
+	})
+}
+
+// alignedChunk allocates `size` bytes aligned to
+// `align` out of a slightly larger allocation.
+func alignedChunk(size int, align int) []byte {
+	var (
+		chunk []byte = make([]byte, size+align-1)
+		off   int    = int(-uintptr(unsafe.Pointer(&chunk[0])) & uintptr(align-1))
+	)
+	return chunk[off : off+size : off+size]
+}
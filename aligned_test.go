@@ -0,0 +1,82 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestAligned(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithLocalCacheSize(0), WithSlabs(64<<10, 256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, align := range []int{16, 64, 512, 4096} {
+		for _, size := range []int{1, 100, 512, 5000} {
+			b := bp.GetAligned(size, align)
+			if len(b) != size {
+				t.Fatal("invalid length", len(b), size)
+			}
+			if p := uintptr(unsafe.Pointer(&b[0])); p%uintptr(align) != 0 {
+				t.Fatal("misaligned buffer", align, p)
+			}
+			bp.ReleaseAligned(b, align)
+			if c := bp.GetAligned(size, align); &c[0] != &b[0] {
+				t.Fatal("expected reuse", align, size)
+			}
+		}
+	}
+	// a plain buffer must not leak into aligned
+	// classes.
+	plain := make([]byte, 4097)[1:]
+	bp.ReleaseAligned(plain, 4096)
+	if c := bp.GetAligned(4096, 4096); uintptr(unsafe.Pointer(&c[0]))%4096 != 0 {
+		t.Fatal("misaligned buffer")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+		bp.GetAligned(64, 3)
+	}()
+}
+
+func TestAlignedLayout(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithLayout(Geometric(1.25)), WithClassRetained(176, 2), WithLocalCacheSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.GetAligned(100, 128)
+	if len(b) != 100 || uintptr(unsafe.Pointer(&b[0]))%128 != 0 {
+		t.Fatal("invalid aligned buffer", len(b))
+	}
+	bp.ReleaseAligned(b, 128)
+	if c := bp.GetAligned(100, 128); &c[0] != &b[0] {
+		t.Fatal("expected reuse")
+	}
+}
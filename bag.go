@@ -0,0 +1,102 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// - MARK: Bag section.
+
+// Bag is an unordered lock-free collection,
+// the segment chain `LFPool` is built on.
+// Every inserted value is returned by
+// exactly one `Get` or `Drain`: values are
+// neither lost nor duplicated. `Get` may
+// report an empty bag while an `Insert` is
+// in flight, a bag is only known empty when
+// no `Insert` runs concurrently. `Insert`
+// allocates one box per value, the chain
+// keeps its largest size once grown.
+type Bag[T any] struct {
+	slot pslot
+	len  int64
+}
+
+// NewBag initializes and allocates a new
+// `Bag` and returns a pointer to it.
+func NewBag[T any]() *Bag[T] {
+	var b *Bag[T] = &Bag[T]{}
+	b.slot.seg = cLFSize
+	b.slot.entry = unsafe.Pointer(newlfsliceSize(b.slot.seg))
+	return b
+}
+
+// Insert adds `x` to the bag.
+func (b *Bag[T]) Insert(x T) {
+	var box *T = new(T)
+	*box = x
+	(*lfslice)(b.slot.ldEntry()).push(unsafe.Pointer(box))
+	atomic.AddInt64(&b.len, 1)
+}
+
+// Get removes and returns a value, the
+// boolean is false when none was found.
+func (b *Bag[T]) Get() (T, bool) {
+	var zero T
+	v := (*lfslice)(b.slot.ldEntry()).pop()
+	if v == nil {
+		return zero, false
+	}
+	atomic.AddInt64(&b.len, -1)
+	return *(*T)(v), true
+}
+
+// Len returns the number of values in the
+// bag. It is exact when no call runs
+// concurrently.
+func (b *Bag[T]) Len() int {
+	if n := atomic.LoadInt64(&b.len); n > 0 {
+		return int(n)
+	}
+	return 0
+}
+
+// Drain removes every value, calling `fn`
+// with each of them, and returns their
+// number. Values inserted concurrently may
+// or may not be drained.
+func (b *Bag[T]) Drain(fn func(T)) int {
+	var n int
+	for {
+		x, ok := b.Get()
+		if !ok {
+			return n
+		}
+		if fn != nil {
+			fn(x)
+		}
+		n++
+	}
+}
@@ -0,0 +1,162 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBag(t *testing.T) {
+	b := NewBag[string]()
+	if _, ok := b.Get(); ok {
+		t.Fatal("expected an empty bag")
+	}
+	for _, s := range []string{"a", "b", "c"} {
+		b.Insert(s)
+	}
+	if n := b.Len(); n != 3 {
+		t.Fatal("invalid length", n)
+	}
+	x, ok := b.Get()
+	if !ok || (x != "a" && x != "b" && x != "c") {
+		t.Fatal("invalid value", x, ok)
+	}
+	seen := map[string]bool{x: true}
+	if n := b.Drain(func(s string) { seen[s] = true }); n != 2 || len(seen) != 3 {
+		t.Fatal("invalid drain", n, seen)
+	}
+	if n := b.Len(); n != 0 {
+		t.Fatal("invalid length", n)
+	}
+}
+
+// TestBagContract checks that, with concurrent
+// producers and consumers spanning many
+// segments, every value is taken exactly once.
+func TestBagContract(t *testing.T) {
+	const (
+		producers = 4
+		consumers = 4
+		per       = 5000
+	)
+	var (
+		b     *Bag[int] = NewBag[int]()
+		seen  []int32   = make([]int32, producers*per)
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		done  = make(chan struct{})
+		taken = func(x int) {
+			mu.Lock()
+			seen[x]++
+			mu.Unlock()
+		}
+	)
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				b.Insert(p*per + i)
+			}
+		}(p)
+	}
+	var cwg sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		cwg.Add(1)
+		go func() {
+			defer cwg.Done()
+			for {
+				if x, ok := b.Get(); ok {
+					taken(x)
+					continue
+				}
+				select {
+				case <-done:
+					return
+				default:
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	cwg.Wait()
+	b.Drain(taken)
+	for x, n := range seen {
+		if n != 1 {
+			t.Fatal("value taken", n, "times", x)
+		}
+	}
+	if n := b.Len(); n != 0 {
+		t.Fatal("invalid length", n)
+	}
+}
+
+// TestBagNoFalseEmpty checks that, without
+// concurrent inserts, `Get` only reports an
+// empty bag once every value was taken. A
+// getter counts itself as a claim before
+// calling `Get`: when it finds the bag empty,
+// the other claims must cover every value.
+func TestBagNoFalseEmpty(t *testing.T) {
+	const (
+		values  = 2000
+		getters = 8
+	)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for round := 0; round < 8; round++ {
+		var (
+			b      *Bag[int] = NewBag[int]()
+			claims int64
+			wg     sync.WaitGroup
+		)
+		for i := 0; i < values; i++ {
+			b.Insert(i)
+		}
+		for g := 0; g < getters; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					atomic.AddInt64(&claims, 1)
+					if _, ok := b.Get(); ok {
+						continue
+					}
+					if c := atomic.LoadInt64(&claims) - 1; c < values {
+						t.Error("false empty after", c, "claims")
+					}
+					atomic.AddInt64(&claims, -1)
+					return
+				}
+			}()
+		}
+		wg.Wait()
+		if n := b.Drain(nil); n != 0 {
+			t.Fatal("values left behind", n)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// - MARK: Layout section.
+
+const (
+	cSubBits  = 4
+	cSubSteps = 1 << cSubBits
+	cAlign    = 8
+)
+
+// Layout produces the class sizes of a pool.
+// `Classes` returns an ascending list of
+// sizes, classes outside `[min, max]` are
+// kept in the table but never used.
+type Layout interface {
+	Classes(min, max int) []int
+}
+
+type pow2Layout struct{}
+
+type geometricLayout struct {
+	factor float64
+}
+
+// classTable maps sizes to class indexes
+// in constant time. `lookup` holds, per
+// (log2, sub-step) bucket, the smallest
+// class that can serve the bucket's lower
+// bound.
+type classTable struct {
+	sizes  []int
+	lookup []uint16
+	lo     int // smallest active class index
+	hi     int // largest active class index
+}
+
+// PowerOfTwo returns the default layout,
+// one class per power of two.
+func PowerOfTwo() Layout {
+	return pow2Layout{}
+}
+
+// Geometric returns a layout where every
+// class is `factor` times larger than the
+// previous one, rounded up to 8 bytes.
+// `factor` must be in (1, 2].
+func Geometric(factor float64) Layout {
+	return geometricLayout{factor}
+}
+
+func (l pow2Layout) Classes(min, max int) []int {
+	return append([]int(nil), blocks...)
+}
+
+func (l geometricLayout) Classes(min, max int) []int {
+	var (
+		ret  []int = []int{min}
+		size int   = min
+	)
+	for size < max {
+		next := int(float64(size) * l.factor)
+		next = (next + cAlign - 1) &^ (cAlign - 1)
+		if next <= size {
+			next = size + cAlign
+		}
+		if next > max {
+			next = max
+		}
+		ret = append(ret, next)
+		size = next
+	}
+	return ret
+}
+
+// - MARK: classTable section.
+
+// newClassTable builds the lookup table for
+// `sizes` merged with `pinned`, activating
+// the classes within `[min, max]`.
+func newClassTable(sizes []int, pinned []int, min, max int) (*classTable, error) {
+	var (
+		merged []int = make([]int, 0, len(sizes)+len(pinned))
+		ct     *classTable
+	)
+	merged = append(merged, sizes...)
+	merged = append(merged, pinned...)
+	sort.Ints(merged)
+	ct = &classTable{sizes: merged[:0], lo: -1, hi: -1}
+	for _, size := range merged {
+		if size <= 0 {
+			return nil, &OptionError{"Layout", size, "class sizes must be positive"}
+		}
+		if n := len(ct.sizes); n > 0 && ct.sizes[n-1] == size {
+			continue
+		}
+		ct.sizes = append(ct.sizes, size)
+	}
+	for i, size := range ct.sizes {
+		if size >= min && ct.lo == -1 {
+			ct.lo = i
+		}
+		if size <= max {
+			ct.hi = i
+		}
+	}
+	if ct.lo == -1 || ct.hi < ct.lo || ct.sizes[ct.lo] != min || ct.sizes[ct.hi] != max {
+		return nil, &OptionError{"Layout", fmt.Sprint(ct.sizes), "must contain MinSize and MaxSize"}
+	}
+	if len(ct.sizes) > 1<<16 {
+		return nil, &OptionError{"Layout", len(ct.sizes), "too many classes"}
+	}
+	ct.lookup = make([]uint16, classKey(max)+1)
+	idx := ct.lo
+	for key := range ct.lookup {
+		for idx < ct.hi && ct.sizes[idx] < keyFloor(key) {
+			idx++
+		}
+		ct.lookup[key] = uint16(idx)
+	}
+	return ct, nil
+}
+
+// classKey maps `size` to its lookup bucket,
+// a float-like encoding of `size-1` with
+// `cSubBits` bits of mantissa.
+func classKey(size int) int {
+	var m uint = uint(size - 1)
+	if m < cSubSteps {
+		return int(m)
+	}
+	b := bits.Len(m)
+	sub := (m >> uint(b-1-cSubBits)) & (cSubSteps - 1)
+	return (b-cSubBits)<<cSubBits | int(sub)
+}
+
+// keyFloor returns the smallest size that
+// maps to `key`.
+func keyFloor(key int) int {
+	if key < cSubSteps {
+		return key + 1
+	}
+	b := key>>cSubBits + cSubBits
+	sub := key & (cSubSteps - 1)
+	return (1<<uint(b-1) | sub<<uint(b-1-cSubBits)) + 1
+}
+
+// index returns the smallest active class
+// that can hold `size`. Sizes above the
+// largest class map to it.
+func (ct *classTable) index(size int) int {
+	if size <= ct.sizes[ct.lo] {
+		return ct.lo
+	}
+	if size >= ct.sizes[ct.hi] {
+		return ct.hi
+	}
+	idx := int(ct.lookup[classKey(size)])
+	for ct.sizes[idx] < size {
+		idx++
+	}
+	return idx
+}
+
+// size returns the capacity of class `index`.
+func (ct *classTable) size(index int) int {
+	return ct.sizes[index]
+}
+
+// active returns the sizes of usable classes.
+func (ct *classTable) active() []int {
+	return append([]int(nil), ct.sizes[ct.lo:ct.hi+1]...)
+}
@@ -0,0 +1,76 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"testing"
+)
+
+func TestClassTable(t *testing.T) {
+	for _, layout := range []Layout{PowerOfTwo(), Geometric(1.125), Geometric(1.25), Geometric(2)} {
+		ct, err := newClassTable(layout.Classes(64, 1<<20), []int{1500, 9000, 65535}, 64, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for size := 1; size <= 1<<20; size++ {
+			idx := ct.index(size)
+			if ct.sizes[idx] < size && size > 64 {
+				t.Fatal("class too small", size, ct.sizes[idx])
+			}
+			if idx > ct.lo && ct.sizes[idx-1] >= size {
+				t.Fatal("class not minimal", size, ct.sizes[idx])
+			}
+		}
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithLayout(Geometric(1.125)), WithClasses(1500, 9000, 65535), WithLocalCacheSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{1500, 9000, 65535} {
+		if c := cap(bp.Get(size)); c != size {
+			t.Fatal("invalid capacity", size, c)
+		}
+	}
+	if c := cap(bp.Get(65 << 10)); float64(c) > 65*1024*1.125 {
+		t.Fatal("invalid capacity", c)
+	}
+	b := bp.Get(1500)
+	bp.Release(b)
+	if c := bp.Get(1500); &c[0] != &b[0] {
+		t.Fatal("expected reuse")
+	}
+	if _, err := NewLFPoolWithOptions(WithLayout(Geometric(1))); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := NewLFPoolWithOptions(WithClasses(1 << 30)); err == nil {
+		t.Fatal("expected error")
+	}
+	if c := NewLFPool().Get(65); cap(c) != 128 {
+		t.Fatal("invalid capacity", cap(c))
+	}
+}
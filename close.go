@@ -0,0 +1,107 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync/atomic"
+)
+
+// - MARK: lifecycle section.
+
+// Close stops the janitor and the GC victim
+// generation and drops every retained buffer.
+// Afterwards `Get` falls back to plain
+// allocations, `AutoGet` and friends return
+// `ErrClosed` and releases are ignored but
+// for unmapping off-heap buffers. It returns
+// `ErrClosed` when already closed.
+func (lfp *LFPool) Close() error {
+	if !atomic.CompareAndSwapUint32(&lfp.closed, 0, 1) {
+		return ErrClosed
+	}
+	lfp.Stop()
+	atomic.StoreUint32(&lfp.gcoff, 1)
+	for index := lfp.classes.lo; index <= lfp.classes.hi; index++ {
+		lfp.drain(index)
+		if lfp.slabs != nil {
+			atomic.StorePointer(&lfp.slabs[index], nil)
+		}
+	}
+	lfp.flushHuge(true)
+	lfp.views.Clear()
+	lfp.eachAligned(func(child *LFPool) {
+		child.Close()
+	})
+	return nil
+}
+
+// releaseClosed lets go of `chunk` released
+// after `Close`, its records are deleted and
+// off-heap memory is unmapped.
+func (lfp *LFPool) releaseClosed(chunk []byte) {
+	if cap(chunk) == 0 {
+		return
+	}
+	if lfp.profile != nil {
+		lfp.profileRemove(chunk)
+	}
+	lfp.forget(chunk)
+}
+
+// Drain drops every retained buffer of the
+// class of capacity `size`, as listed by
+// `Classes`, and returns the freed bytes.
+func (lfp *LFPool) Drain(size int) int64 {
+	var index int = lfp.classes.index(size)
+	if lfp.classes.size(index) != size {
+		return 0
+	}
+	return lfp.drain(index) * int64(size)
+}
+
+// drain empties class `index`, victims and
+// local caches included, and returns the
+// number of dropped buffers.
+func (lfp *LFPool) drain(index int) int64 {
+	defer endRegion(lfp.region("lfpool.drain"))
+	var n int64 = lfp.cleanUp(index, lfp.slots[index].detach())
+	if lfp.victims != nil {
+		n += lfp.cleanUp(index, lfp.victims[index].detach())
+	}
+	n += lfp.flushLocal(index, -1)
+	lfp.detached(index, n)
+	return n
+}
+
+// plain allocates a buffer for a request of
+// `size` bytes outside of the pool, class
+// sized up to `MaxSize`.
+func (lfp *LFPool) plain(size int) []byte {
+	if size <= lfp.opts.MaxSize {
+		size = lfp.classes.size(lfp.classes.index(size))
+	}
+	if lfp.align > 0 {
+		return alignedChunk(size, lfp.align)
+	}
+	return make([]byte, size)
+}
@@ -0,0 +1,129 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(
+		WithStats(true),
+		WithAuto(true, 0, 0),
+		WithGCVictim(true),
+		WithTrim(time.Hour, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		bp.Release(make([]byte, 1024))
+		bp.ReleaseAligned(bp.GetAligned(1024, 512), 512)
+	}
+	if err := bp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&bp.retained); n != 0 {
+		t.Fatal("invalid retained bytes", n)
+	}
+	if bp.jan.stop != nil {
+		t.Fatal("janitor still running")
+	}
+	b := bp.Get(1000)
+	if cap(b) != 1024 {
+		t.Fatal("invalid capacity", cap(b))
+	}
+	bp.Release(b)
+	if n := atomic.LoadInt64(&bp.retained); n != 0 {
+		t.Fatal("release after close retained a buffer", n)
+	}
+	if _, err := bp.AutoGet(); err != ErrClosed {
+		t.Fatal("expected ErrClosed", err)
+	}
+	if _, err := bp.GetAutoBuffer(); err != ErrClosed {
+		t.Fatal("expected ErrClosed", err)
+	}
+	if err := bp.Close(); err != ErrClosed {
+		t.Fatal("expected ErrClosed", err)
+	}
+	if err := bp.Start(); err != ErrClosed || bp.jan.stop != nil {
+		t.Fatal("janitor started after close", err)
+	}
+}
+
+func TestCloseRace(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for i := 0; i < 200; i++ {
+		bp, err := NewLFPoolWithOptions(WithStats(true), WithLocalCacheSize(i%2*8))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 256; j++ {
+					bp.Release(make([]byte, 1024))
+				}
+			}()
+		}
+		for atomic.LoadInt64(&bp.retained) == 0 {
+			runtime.Gosched()
+		}
+		bp.Close()
+		wg.Wait()
+		if n := atomic.LoadInt64(&bp.retained); n != 0 {
+			t.Fatal("buffers retained after close", n)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		bp.Release(make([]byte, 1024))
+		bp.Release(make([]byte, 2048))
+	}
+	if n := bp.Drain(1000); n != 0 {
+		t.Fatal("drained a non class size", n)
+	}
+	if n := bp.Drain(1024); n != 4*1024 {
+		t.Fatal("invalid freed bytes", n)
+	}
+	if n := atomic.LoadInt64(&bp.retained); n != 4*2048 {
+		t.Fatal("invalid retained bytes", n)
+	}
+}
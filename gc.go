@@ -0,0 +1,76 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+	"weak"
+)
+
+// - MARK: GC victim section.
+
+// gcSentinel is an unreachable object whose
+// finalizer runs once per GC cycle. It only
+// holds a weak reference so the pool can
+// still be collected.
+type gcSentinel struct {
+	pool weak.Pointer[LFPool]
+}
+
+// armGC registers the first sentinel of `lfp`.
+func (lfp *LFPool) armGC() {
+	var s *gcSentinel = &gcSentinel{weak.Make(lfp)}
+	runtime.SetFinalizer(s, (*gcSentinel).fire)
+}
+
+func (s *gcSentinel) fire() {
+	lfp := s.pool.Value()
+	if lfp == nil || atomic.LoadUint32(&lfp.gcoff) != 0 {
+		return
+	}
+	lfp.onGC()
+	runtime.SetFinalizer(s, (*gcSentinel).fire)
+}
+
+// onGC ages every class by one generation:
+// the primary chain, along with the local
+// caches, becomes the victim chain and the
+// previous victims are dropped. It returns
+// the freed bytes.
+func (lfp *LFPool) onGC() int64 {
+	var freed int64
+	atomic.AddUint64(&lfp.gcs, 1)
+	for index := lfp.classes.lo; index <= lfp.classes.hi; index++ {
+		primary := lfp.slots[index].detach()
+		lfp.drainLocal(index, -1, func(chunk []byte) {
+			primary.Insert(chunk)
+		})
+		old := atomic.SwapPointer(&lfp.victims[index].entry, unsafe.Pointer(primary))
+		n := lfp.cleanUp(index, (*lfslice)(old))
+		lfp.detached(index, n)
+		freed += n * int64(lfp.classes.size(index))
+	}
+	return freed
+}
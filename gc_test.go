@@ -0,0 +1,80 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGCVictim(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithGCVictim(true), WithStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stop the sentinel, generations are aged
+	// by hand.
+	atomic.StoreUint32(&bp.gcoff, 1)
+	b := bp.Get(2048)
+	bp.Release(b)
+	bp.onGC()
+	if c := bp.Get(2048); &c[0] != &b[0] {
+		t.Fatal("expected buffer from victim")
+	}
+	bp.Release(b)
+	bp.onGC()
+	if n := bp.onGC(); n != 2048 {
+		t.Fatal("invalid freed bytes", n)
+	}
+	if c := bp.Get(2048); &c[0] == &b[0] {
+		t.Fatal("expected a new buffer")
+	}
+	if n := atomic.LoadInt64(&bp.retained); n != 0 {
+		t.Fatal("invalid retained bytes", n)
+	}
+}
+
+func TestGCVictimCycles(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithGCVictim(true), WithStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 16; i++ {
+		bp.Release(make([]byte, 4096))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&bp.retained) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("buffers survived GC cycles", atomic.LoadInt64(&bp.retained))
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if atomic.LoadUint64(&bp.gcs) < 2 {
+		t.Fatal("invalid GC cycle count", atomic.LoadUint64(&bp.gcs))
+	}
+}
@@ -0,0 +1,166 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// - MARK: leak section.
+
+const (
+	cLeakDepth = 32
+)
+
+// Leak groups outstanding buffers handed out
+// from the same call stack. Buffers whose
+// stack was not sampled share a group with
+// an empty `Stack`.
+type Leak struct {
+	Stack []uintptr
+	Count int
+	Bytes int64
+}
+
+// pkgPrefix is the import path of lfpool as
+// it appears in function names.
+var pkgPrefix string = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+1+strings.Index(name[slash+1:], ".")]
+}()
+
+// sample returns the caller stack of every
+// `LeakSampleRate`th call, nil otherwise.
+func (lfp *LFPool) sample() []uintptr {
+	var rate uint64 = uint64(lfp.opts.LeakSampleRate)
+	if rate > 1 && atomic.AddUint64(&lfp.samples, 1)%rate != 0 {
+		return nil
+	}
+	var (
+		pcs [cLeakDepth]uintptr
+		n   int = runtime.Callers(3, pcs[:])
+	)
+	return append([]uintptr(nil), pcs[:n]...)
+}
+
+// Outstanding returns the buffers currently
+// handed out, grouped by call stack, largest
+// groups first. Leak tracking must be on.
+// Buffers the GC collected are no longer
+// reported.
+func (lfp *LFPool) Outstanding() []Leak {
+	var (
+		groups map[string]*Leak = make(map[string]*Leak)
+		ret    []Leak
+	)
+	lfp.outstanding(groups)
+	for _, g := range groups {
+		ret = append(ret, *g)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Bytes != ret[j].Bytes {
+			return ret[i].Bytes > ret[j].Bytes
+		}
+		return ret[i].Count > ret[j].Count
+	})
+	return ret
+}
+
+func (lfp *LFPool) outstanding(groups map[string]*Leak) {
+	lfp.owners.Range(func(_, v interface{}) bool {
+		o := v.(*owner)
+		if atomic.LoadUint32(&o.state) != ownOut {
+			return true
+		}
+		stack := trimStack(o.stack)
+		key := fmt.Sprint(stack)
+		g, ok := groups[key]
+		if !ok {
+			g = &Leak{Stack: stack}
+			groups[key] = g
+		}
+		g.Count++
+		g.Bytes += int64(o.size)
+		return true
+	})
+	lfp.eachAligned(func(child *LFPool) {
+		child.outstanding(groups)
+	})
+}
+
+// WriteLeakReport writes the groups returned
+// by `Outstanding` to `w`.
+func (lfp *LFPool) WriteLeakReport(w io.Writer) error {
+	var (
+		leaks []Leak = lfp.Outstanding()
+		count int
+		size  int64
+	)
+	for _, l := range leaks {
+		count += l.Count
+		size += l.Bytes
+	}
+	if _, err := fmt.Fprintf(w, "lfpool: %d outstanding buffers, %d bytes\n", count, size); err != nil {
+		return err
+	}
+	for _, l := range leaks {
+		stack := "\t(not sampled)\n"
+		if len(l.Stack) > 0 {
+			stack = formatStack(l.Stack)
+		}
+		if _, err := fmt.Fprintf(w, "\n%d buffers, %d bytes, handed out at:\n%s", l.Count, l.Bytes, stack); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trimStack drops the leading frames that
+// belong to the pool API, so that stacks
+// start at the caller. A PC is dropped when
+// its outermost frame, the function it
+// physically belongs to, is a pool method.
+func trimStack(pcs []uintptr) []uintptr {
+	var skip int
+	for ; skip < len(pcs); skip++ {
+		var (
+			frames *runtime.Frames = runtime.CallersFrames(pcs[skip : skip+1])
+			frame  runtime.Frame
+		)
+		for more := true; more; {
+			frame, more = frames.Next()
+		}
+		if !strings.HasPrefix(frame.Function, pkgPrefix+".(*LFPool).") &&
+			!strings.HasPrefix(frame.Function, pkgPrefix+".(*Buffer).") {
+			break
+		}
+	}
+	return pcs[skip:]
+}
@@ -0,0 +1,100 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func leakA(bp *LFPool) []byte { return bp.Get(1000) }
+
+func leakB(bp *LFPool) *Buffer { return bp.GetBuffer(4096) }
+
+func TestLeaks(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithLeakTracking(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		leakA(bp)
+	}
+	b := leakB(bp)
+	bp.Release(leakA(bp))
+	leaks := bp.Outstanding()
+	if len(leaks) != 2 {
+		t.Fatal("invalid group count", len(leaks))
+	}
+	if leaks[0].Count != 1 || leaks[0].Bytes != 4096 {
+		t.Fatal("invalid group", leaks[0])
+	}
+	if leaks[1].Count != 3 || leaks[1].Bytes != 3*1024 {
+		t.Fatal("invalid group", leaks[1])
+	}
+	var out bytes.Buffer
+	if err := bp.WriteLeakReport(&out); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+	t.Log(report)
+	if !strings.Contains(report, "4 outstanding buffers, 7168 bytes") ||
+		!strings.Contains(report, "leakA") || !strings.Contains(report, "leakB") {
+		t.Fatal("invalid report", report)
+	}
+	if strings.Contains(report, "(*LFPool).fetch") {
+		t.Fatal("pool frames in report", report)
+	}
+	b.Release()
+	if n := len(bp.Outstanding()); n != 1 {
+		t.Fatal("invalid group count", n)
+	}
+	o := DefaultOptions()
+	o.LeakTracking = true
+	if _, err := NewLFPoolWithOptions(WithOptions(o)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLeaksSampled(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithLeakTracking(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		leakA(bp)
+	}
+	var sampled, unsampled int
+	for _, l := range bp.Outstanding() {
+		if len(l.Stack) > 0 {
+			sampled += l.Count
+		} else {
+			unsampled += l.Count
+		}
+	}
+	if sampled != 2 || unsampled != 6 {
+		t.Fatal("invalid sampling", sampled, unsampled)
+	}
+}
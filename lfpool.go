@@ -25,7 +25,10 @@ package lfpool
 import (
 	"errors"
 	"io"
+	"math"
 	"runtime"
+	"runtime/pprof"
+	"sync"
 	"sync/atomic"
 	"unsafe"
 )
@@ -40,6 +43,7 @@ import (
 const (
 	lBlkMin   = 0xffffffc0
 	lBlkMax   = 0x02000000
+	lBlkTop   = 0x100000000
 	cMin32    = 0x00000000
 	cDeBruijn = 0x07C4ACDD
 	cLFSize   = 16
@@ -58,7 +62,12 @@ var (
 )
 
 var (
-	LPNotSupported error = errors.New("lfpool: op not supported.")
+	LPNotSupported   error = errors.New("lfpool: op not supported.")
+	ErrClosed        error = errors.New("lfpool: pool closed.")
+	ErrTooLarge      error = errors.New("lfpool: size above the largest class.")
+	ErrInvalidSize   error = errors.New("lfpool: invalid size.")
+	ErrForeign       error = errors.New("lfpool: buffer not from this pool.")
+	ErrDoubleRelease error = errors.New("lfpool: buffer already released.")
 )
 
 var mDeBruijnBitPosition [32]int = [32]int{
@@ -66,40 +75,63 @@ var mDeBruijnBitPosition [32]int = [32]int{
 	8, 12, 20, 28, 15, 17, 24, 7, 19, 27, 23, 6, 26, 5, 4, 31,
 }
 
-var blocks blktable = blktable{
-	2, 4, 8,
-	16, 32, 64,
-	128, 256, 512,
-	1024, 2048, 4096, 8192,
-	16384, 32768, 65536, 131072,
-	262144, 524288, 1048576, 2097152,
-	4194304, 8388608, 16777216,
-	33554432, 67108864, 134217728,
-	268435456, 536870912, 1073741824,
-	2147483648, 4294967296,
-}
+var blocks blktable = newBlktable()
 
 type blktable []int
 
 type LFPool struct {
-	slots [32]pslot
-	stats *Stats
+	retained int64 // bytes
+	slots    []pslot
+	counts   []pcount
+	victims  []pslot
+	slabs    []unsafe.Pointer // *slab
+	nslabs   int64
+	locals   []plocal
+	classes  *classTable
+	caps     []int64
+	huge     hugeCache
+	stats    *Stats
+	opts     Options
+	track    bool
+	gcoff    uint32
+	closed   uint32
+	shrink   uint32
+	gcs      uint64
+	mmaps    sync.Map // base address -> mapping size
+	aligned  sync.Map // alignment -> *LFPool
+	poisons  sync.Map // base address -> []uintptr
+	owners   sync.Map // base address -> *owner
+	views    sync.Map // end address -> *viewRec
+	samples  uint64
+	profile  *pprof.Profile
+	amu      sync.Mutex
+	align    int
+	jan      janitor
 }
 
 type Stats struct {
-	blocks [32]stat
-	defbs  uint64
-	max    uint64
-	safe   uint32
-	auto   uint32
+	blocks     []stat
+	huge       stat   // oversized buffers
+	peak       uint64 // peak retained bytes
+	sizes      []int
+	defbs      uint64
+	max        uint64
+	maxsz      uint64
+	percentile float64
+	safe       uint32
+	auto       uint32
 }
 
 type stat struct {
-	allocs   uint64
+	allocs   uint64 // misses
+	hits     uint64
 	rels     uint64
 	deallocs uint64
-	min      uint64
-	max      uint64
+	slabs    uint64
+	copies   uint64 // misfit releases copied up
+	rounds   uint64 // misfit releases rounded down
+	discards uint64 // misfit releases dropped
+	max      uint64 // peak retained buffers
 }
 
 type lbstat struct {
@@ -113,14 +145,24 @@ type markedPtr struct {
 }
 
 type lfslice struct {
-	data  [cLFSize]unsafe.Pointer
+	data  []unsafe.Pointer
 	count uint32
+	dead  uint32         // set on the head of a cleaned up chain
 	next  unsafe.Pointer // *markedPtr
 }
 
+// pcount accounts the buffers retained by
+// the `pslot` of the same index.
+type pcount struct {
+	count int64 // retained buffers
+	low   int64 // lowest count since `since`
+	since int64 // start of the idle window
+}
+
 type pslot struct {
 	entry unsafe.Pointer // *lfslice
 	flag  uint32
+	seg   uint32 // segment width
 }
 
 type Buffer struct {
@@ -132,12 +174,10 @@ type Buffer struct {
 // - MARK: alloc/init section.
 
 // NewLFPool initializes and allocates a new
-// `LFPool` and returns a pointer to it.
+// `LFPool` with default options and returns
+// a pointer to it.
 func NewLFPool() *LFPool {
-	var lfp *LFPool = &LFPool{stats: nil}
-	for i, _ := range lfp.slots {
-		lfp.slots[i].entry = unsafe.Pointer(newlfslice())
-	}
+	lfp, _ := NewLFPoolWithOptions()
 	return lfp
 }
 
@@ -146,22 +186,107 @@ func NewLFPool() *LFPool {
 // embedded statistics struct `Stats`
 // and returns a pointer to it.
 func NewLFPoolWithStats() *LFPool {
-	var lfp *LFPool = &LFPool{stats: &Stats{}}
+	lfp, _ := NewLFPoolWithOptions(WithStats(true))
+	return lfp
+}
+
+// NewLFPoolWithOptions initializes and
+// allocates a new `LFPool` configured by
+// `opts`. It returns an `*OptionError`
+// when the resulting `Options` are invalid.
+func NewLFPoolWithOptions(opts ...Option) (*LFPool, error) {
+	var o Options = DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if o.Layout == nil {
+		o.Layout = PowerOfTwo()
+	}
+	ct, err := newClassTable(o.Layout.Classes(o.MinSize, o.MaxSize), o.Classes, o.MinSize, o.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	caps, err := o.limits(ct)
+	if err != nil {
+		return nil, err
+	}
+	var lfp *LFPool = &LFPool{
+		slots:   make([]pslot, len(ct.sizes)),
+		counts:  make([]pcount, len(ct.sizes)),
+		classes: ct,
+		caps:    caps,
+		opts:    o,
+		track:   o.tracked(),
+	}
+	if o.LocalCacheSize > 0 {
+		lfp.locals = newLocals(len(ct.sizes), o.LocalCacheSize)
+	}
+	if o.Stats {
+		lfp.stats = &Stats{
+			blocks:     make([]stat, len(ct.sizes)),
+			sizes:      ct.sizes,
+			maxsz:      uint64(o.AutoMaxSize),
+			percentile: o.Percentile,
+		}
+		if o.Auto {
+			lfp.stats.defbs = uint64(o.MinSize)
+			lfp.stats.max = uint64(o.AutoMaxSize)
+			lfp.stats.auto = 1
+		}
+	}
 	for i, _ := range lfp.slots {
-		lfp.slots[i].entry = unsafe.Pointer(newlfslice())
+		lfp.slots[i].seg = uint32(o.SegmentSize)
+		lfp.slots[i].entry = unsafe.Pointer(newlfsliceSize(lfp.slots[i].seg))
 	}
-	return lfp
+	if o.ProfileName != "" {
+		if lfp.profile, err = newProfile(o.ProfileName); err != nil {
+			return nil, err
+		}
+	}
+	if o.SlabSize > 0 {
+		lfp.slabs = make([]unsafe.Pointer, len(ct.sizes))
+	}
+	if o.GCVictim {
+		lfp.victims = make([]pslot, len(ct.sizes))
+		for i, _ := range lfp.victims {
+			lfp.victims[i].seg = uint32(o.SegmentSize)
+			lfp.victims[i].entry = unsafe.Pointer(newlfsliceSize(lfp.victims[i].seg))
+		}
+		lfp.armGC()
+	}
+	return lfp, nil
 }
 
 // newlfslice initializes and allocates
 // a new `lfslice` and returns a pointer
 // to it.
 func newlfslice() *lfslice {
-	return &lfslice{}
+	return newlfsliceSize(cLFSize)
+}
+
+// newlfsliceSize initializes and allocates
+// a new `lfslice` with `size` slots and
+// returns a pointer to it.
+func newlfsliceSize(size uint32) *lfslice {
+	return &lfslice{data: make([]unsafe.Pointer, size)}
 }
 
 // - MARK: blktable section.
 
+// newBlktable returns the powers of two from
+// 2 up to `lBlkTop`, or up to the largest one
+// an `int` holds on 32-bit platforms.
+func newBlktable() blktable {
+	var ret blktable
+	for size := uint64(2); size <= lBlkTop && size <= math.MaxInt; size <<= 1 {
+		ret = append(ret, int(size))
+	}
+	return ret
+}
+
 // bin returns the nearest log of nearest
 // power of two from its table. It is used
 // to find bin slots.
@@ -200,6 +325,13 @@ func (ps *pslot) ldEntry() unsafe.Pointer {
 	return atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&ps.entry)))
 }
 
+func (ps *pslot) newSegment() *lfslice {
+	if ps.seg == 0 {
+		return newlfslice()
+	}
+	return newlfsliceSize(ps.seg)
+}
+
 func (ps *pslot) detach() *lfslice {
 	var (
 		hptr unsafe.Pointer
@@ -208,8 +340,8 @@ func (ps *pslot) detach() *lfslice {
 		hptr = atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&ps.entry)))
 		if atomic.CompareAndSwapPointer(
 			(*unsafe.Pointer)(unsafe.Pointer(&ps.entry)),
-			(unsafe.Pointer)(unsafe.Pointer(ps.entry)),
-			(unsafe.Pointer)(unsafe.Pointer(newlfslice())),
+			(unsafe.Pointer)(hptr),
+			(unsafe.Pointer)(unsafe.Pointer(ps.newSegment())),
 		) {
 			break
 		}
@@ -217,39 +349,101 @@ func (ps *pslot) detach() *lfslice {
 	return (*lfslice)(hptr)
 }
 
+// insert pushes `p` to the chain of `ps`. A
+// chain can be detached and cleaned up while
+// `p` is pushed to it, the pointers found in
+// it afterwards are handed back to the live
+// chain so that none is stranded there.
+func (ps *pslot) insert(p unsafe.Pointer) {
+	var head *lfslice = (*lfslice)(ps.ldEntry())
+	head.push(p)
+	if atomic.LoadUint32(&head.dead) != 0 {
+		for v := head.pop(); v != nil; v = head.pop() {
+			ps.insert(v)
+		}
+	}
+}
+
 // - MARK: LFPool section.
 
-func (lfp *LFPool) cleanUp(head *lfslice) {
-	// TODO:
-	//  . do cleanup, sorting, ....
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-	// for curr := head.Next(); (*lfslice)(curr) != nil; an = ((*markedPtr)((*lfslice)(curr).nextPtr()).Next()) {
-	// }
+// cleanUp drops every buffer of the detached
+// chain `head` of class `index` and returns
+// the number of dropped buffers, which the
+// caller reports to the observer.
+func (lfp *LFPool) cleanUp(index int, head *lfslice) int64 {
+	var n int64 = head.kill(lfp.discarder(index))
+	if n > 0 && lfp.track {
+		lfp.unreserve(index, n)
+	}
+	return n
+}
+
+// detached reports `n` buffers of class
+// `index` dropped at once.
+func (lfp *LFPool) detached(index int, n int64) {
+	if n > 0 && lfp.opts.Observer != nil {
+		lfp.opts.Observer.Detach(lfp.class(index), lfp.classes.size(index), n)
+	}
+}
+
+// Classes returns the capacities of the
+// classes served by the pool, ascending.
+func (lfp *LFPool) Classes() []int {
+	return lfp.classes.active()
+}
+
+// ClassOf returns the capacity of the class
+// serving a request of `size` bytes, zero
+// above `MaxSize`.
+func (lfp *LFPool) ClassOf(size int) int {
+	if size > lfp.opts.MaxSize {
+		return 0
+	}
+	return lfp.classes.size(lfp.classes.index(size))
 }
 
 func (lfp *LFPool) Get(chunks ...int) []byte {
+	return lfp.get(lfp.opts.Zero == ZeroOnGet, chunks)
+}
+
+// GetZeroed is like `Get` but the returned
+// buffer is always zeroed, whatever the
+// zeroing policy of the pool.
+func (lfp *LFPool) GetZeroed(chunks ...int) []byte {
+	return lfp.get(lfp.opts.Zero != ZeroOnRelease, chunks)
+}
+
+func (lfp *LFPool) get(zero bool, chunks []int) []byte {
 	cl := len(chunks)
 	switch cl {
 	case 1:
-		chunk := lfp.getChunk(chunks[0])
+		chunk := lfp.fetch(chunks[0], zero)
+		if lfp.opts.ExactCap {
+			return lfp.view(chunk, chunks[0])
+		}
 		chunk = chunk[:cap(chunk)]
 		return chunk
 	case 2:
 		if chunks[0] > chunks[1] {
 			panic("core(pool): len>cap")
 		}
-		chunk := lfp.getChunk(chunks[1])
+		chunk := lfp.fetch(chunks[1], zero)
+		if lfp.opts.ExactCap {
+			return lfp.view(chunk, chunks[1])[:chunks[0]]
+		}
 		chunk = chunk[:chunks[0]]
 		return chunk
 	default:
-		chunk := lfp.getChunk(0) // 64
+		chunk := lfp.fetch(0, zero) // 64
 		chunk = chunk[:cap(chunk)]
 		return chunk
 	}
 }
 
 func (lfp *LFPool) AutoGet() ([]byte, error) {
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		return nil, ErrClosed
+	}
 	if lfp.stats != nil && atomic.LoadUint32(&lfp.stats.auto) != 0 {
 		chunk := lfp.Get(int(atomic.LoadUint64(&lfp.stats.defbs)))
 		return chunk, nil
@@ -273,6 +467,41 @@ func (lfp *LFPool) GetBuffer(chunks ...int) *Buffer {
 	return b
 }
 
+// TryGet is like `Get(len, cap)` but returns
+// an error instead of panicking:
+// `ErrInvalidSize` for negative sizes or
+// `len > cap`, `ErrTooLarge` above the largest
+// class with `OversizeError` and `ErrClosed`
+// after `Close`.
+func (lfp *LFPool) TryGet(length int, capacity int) ([]byte, error) {
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		return nil, ErrClosed
+	}
+	if length < 0 || length > capacity {
+		return nil, ErrInvalidSize
+	}
+	if capacity > lfp.opts.MaxSize && lfp.opts.Oversize == OversizeError {
+		return nil, ErrTooLarge
+	}
+	if lfp.opts.ExactCap {
+		return lfp.view(lfp.getChunk(capacity), capacity)[:length], nil
+	}
+	return lfp.getChunk(capacity)[:length], nil
+}
+
+// TryRelease is like `Release` but reports
+// buffers it cannot take back: `ErrInvalidSize`
+// below the smallest class, `ErrTooLarge`
+// above the largest one with `OversizeError`,
+// `ErrClosed` after
+// `Close` and, with ownership tracking, a
+// `*ReleaseError` matching `ErrForeign` or
+// `ErrDoubleRelease`. Empty slices are
+// ignored.
+func (lfp *LFPool) TryRelease(chunk []byte) error {
+	return lfp.release(chunk)
+}
+
 func (lfp *LFPool) Release(chunk []byte) {
 	lfp.releaseChunk(chunk)
 }
@@ -291,93 +520,352 @@ func (lfp *LFPool) AutoReleaseBuffer(b *Buffer) {
 
 func (lfp *LFPool) AutoRelease(chunk []byte) {
 	var (
-		capacity = cap(chunk)
-		slot     pslot
+		capacity int
 		nc       uint64
 		np       int
 		index    int
 	)
-	if ((capacity & lBlkMin) == 0) || (capacity > lBlkMax) {
+	if lfp.opts.ExactCap {
+		chunk = lfp.unview(chunk)
+	}
+	capacity = cap(chunk)
+	if capacity == 0 {
+		return
+	}
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		lfp.releaseClosed(chunk)
+		return
+	}
+	if lfp.stats == nil {
+		return
+	}
+	if lfp.opts.TrackOwnership {
+		if err := lfp.disown(chunk); err != nil {
+			lfp.report(err)
+			return
+		}
+	}
+	if lfp.profile != nil {
+		lfp.profileRemove(chunk)
+	}
+	if (capacity < lfp.opts.MinSize) || (capacity > lfp.opts.MaxSize) {
 		return
 	} else {
-		index = blocks.lgb2(uint32(capacity))
+		index = lfp.classes.index(capacity)
 	}
 	nc = atomic.LoadUint64(&lfp.stats.max)
 	if capacity <= int(nc) {
-		np = blocks[index]
+		np = lfp.classes.size(index)
 		if capacity < int(np) {
-			ctmp := make([]byte, np)
-			copy(ctmp, chunk)
-			chunk = ctmp
+			var ok bool
+			if index, chunk, ok = lfp.place(index, chunk); !ok {
+				return
+			}
+		}
+		if lfp.opts.Observer != nil {
+			lfp.opts.Observer.Release(lfp.class(index), cap(chunk))
 		}
-		slotptr := lfp.ldSlot(index, unsafe.Sizeof(slot))
-		entry := (*pslot)(slotptr).ldEntry()
-		(*lfslice)(entry).Insert(chunk)
+		lfp.put(index, chunk)
+		atomic.AddUint64(&lfp.stats.blocks[index].rels, 1)
 	}
 }
 
 func (lfp *LFPool) getChunk(chunk int) []byte {
+	return lfp.fetch(chunk, lfp.opts.Zero == ZeroOnGet)
+}
+
+// fetch returns a buffer of the class serving
+// `chunk`, pooled buffers are zeroed when
+// `zero` is set. New buffers are always zero.
+func (lfp *LFPool) fetch(chunk int, zero bool) []byte {
 	var (
-		slot     pslot
-		ret      []byte
-		capacity int
-		index    int
-		entry    unsafe.Pointer
-		slotPtr  unsafe.Pointer
+		ret []byte
 	)
-	if (chunk & lBlkMin) == 0 {
-		index = blocks.lgb2(0x3f)
-	} else if uint32(chunk) >= lBlkMax {
-		index = blocks.lgb2(0x01ffffff)
+	if chunk > lfp.opts.MaxSize && lfp.opts.Oversize == OversizeError {
+		panic("core(pool): size above the largest class")
+	}
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		return lfp.plain(chunk)
+	}
+	if chunk > lfp.opts.MaxSize {
+		ret = lfp.oversize(chunk, zero)
 	} else {
-		index = blocks.lgb2(uint32(chunk - 1))
+		ret = lfp.fetchClass(lfp.classes.index(chunk), zero)
+	}
+	if lfp.opts.TrackOwnership {
+		lfp.own(ret)
 	}
-	capacity = blocks[index]
-	slotPtr = lfp.ldSlot(index, unsafe.Sizeof(slot))
-	entry = (*pslot)(slotPtr).ldEntry()
-	ret = (*lfslice)(entry).Get()
+	if lfp.profile != nil {
+		lfp.profileAdd(ret)
+	}
+	return ret
+}
+
+// fetchClass returns a buffer of class `index`,
+// see `fetch`.
+func (lfp *LFPool) fetchClass(index int, zero bool) []byte {
+	var ret []byte = lfp.take(index)
 	if ret == nil {
 		if lfp.stats != nil {
 			atomic.AddUint64(&lfp.stats.blocks[index].allocs, 1)
 		}
-		return make([]byte, capacity)
+		r := lfp.region("lfpool.alloc")
+		ret = lfp.alloc(index)
+		endRegion(r)
+		if lfp.opts.Observer != nil {
+			lfp.opts.Observer.Miss(lfp.class(index), cap(ret))
+		}
+	} else {
+		if lfp.stats != nil {
+			atomic.AddUint64(&lfp.stats.blocks[index].hits, 1)
+		}
+		if lfp.opts.Observer != nil {
+			lfp.opts.Observer.Hit(lfp.class(index), cap(ret))
+		}
+		if lfp.opts.Debug {
+			lfp.verify(index, ret)
+			zero = zero || lfp.opts.Zero == ZeroOnRelease
+		}
+		if zero {
+			lfp.zero(ret)
+		}
 	}
 	return ret
 }
 
 func (lfp *LFPool) releaseChunk(chunk []byte) {
+	if err := lfp.release(chunk); err != nil {
+		if _, ok := err.(*ReleaseError); ok {
+			lfp.report(err)
+		}
+	}
+}
+
+// release puts `chunk` back and returns an
+// error when it is rejected, see `TryRelease`.
+func (lfp *LFPool) release(chunk []byte) error {
 	var (
-		capacity = cap(chunk)
-		slot     pslot
+		capacity int
 		np       int
 		index    int
-		entry    unsafe.Pointer
-		slotPtr  unsafe.Pointer
 	)
-	if ((capacity & lBlkMin) == 0) || (capacity > lBlkMax) {
-		return
+	if lfp.opts.ExactCap {
+		chunk = lfp.unview(chunk)
+	}
+	capacity = cap(chunk)
+	if capacity == 0 {
+		// NOTE
+		// . empty slices, e.g. `Get(0)` with
+		//   `ExactCap`, hold no buffer.
+		return nil
+	}
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		lfp.releaseClosed(chunk)
+		return ErrClosed
+	}
+	if lfp.opts.TrackOwnership {
+		if err := lfp.disown(chunk); err != nil {
+			return err
+		}
+	}
+	if lfp.profile != nil {
+		lfp.profileRemove(chunk)
+	}
+	if capacity < lfp.opts.MinSize {
+		return ErrInvalidSize
+	} else if capacity > lfp.opts.MaxSize {
+		if lfp.opts.Oversize == OversizeError {
+			return ErrTooLarge
+		}
+		lfp.releaseHuge(chunk)
+		return nil
 	} else {
-		index = blocks.lgb2(uint32(capacity))
+		index = lfp.classes.index(capacity)
 	}
-	np = blocks[index]
+	np = lfp.classes.size(index)
 	if capacity < int(np) {
-		ctmp := make([]byte, np)
-		copy(ctmp, chunk)
-		chunk = ctmp
+		var ok bool
+		if index, chunk, ok = lfp.place(index, chunk); !ok {
+			return nil
+		}
+	}
+	if lfp.opts.Observer != nil {
+		lfp.opts.Observer.Release(lfp.class(index), cap(chunk))
 	}
-	slotPtr = lfp.ldSlot(index, unsafe.Sizeof(slot))
-	entry = (*pslot)(slotPtr).ldEntry()
-	(*lfslice)(entry).Insert(chunk)
+	lfp.put(index, chunk)
 	if lfp.stats != nil {
 		atomic.AddUint64(&lfp.stats.blocks[index].rels, 1)
 	}
+	return nil
+}
+
+// alloc allocates a new buffer of class
+// `index`, off-heap for classes above
+// `OffHeapSize`, out of a slab for classes
+// up to `SlabMaxSize`.
+func (lfp *LFPool) alloc(index int) []byte {
+	var capacity int = lfp.classes.size(index)
+	if lfp.slabs != nil && capacity <= lfp.opts.SlabMaxSize {
+		return lfp.carve(index)
+	}
+	if lfp.opts.OffHeapSize > 0 && capacity >= lfp.opts.OffHeapSize {
+		if chunk, err := mmapChunk(capacity, lfp.opts.HugePages && capacity >= cHugePage); err == nil {
+			lfp.mmaps.Store(uintptr(unsafe.Pointer(&chunk[0])), capacity)
+			return chunk
+		}
+	}
+	if lfp.align > 0 {
+		return alignedChunk(capacity, lfp.align)
+	}
+	return make([]byte, capacity)
+}
+
+// take pops a buffer of class `index`, from
+// the local cache when enabled, otherwise
+// from the shared chain. It returns nil
+// when the class is empty.
+func (lfp *LFPool) take(index int) []byte {
+	var (
+		entry *lfslice = lfp.entry(index)
+		ret   []byte
+	)
+	if lfp.locals != nil {
+		ret = lfp.getLocal(index, entry)
+	} else {
+		ret = entry.Get()
+	}
+	if ret == nil && lfp.victims != nil {
+		ret = (*lfslice)(lfp.victims[index].ldEntry()).Get()
+	}
+	if ret != nil && lfp.track {
+		lfp.unreserve(index, 1)
+	}
+	return ret
+}
+
+// put pushes `chunk` to class `index`, see
+// `take`.
+func (lfp *LFPool) put(index int, chunk []byte) {
+	if lfp.track && !lfp.reserve(index) {
+		lfp.drop(index, chunk)
+		return
+	}
+	if lfp.opts.Debug {
+		lfp.poison(chunk)
+	} else if lfp.opts.Zero == ZeroOnRelease {
+		lfp.zero(chunk)
+	}
+	if lfp.locals != nil {
+		lfp.putLocal(index, chunk)
+	} else {
+		lfp.insert(index, chunk)
+	}
+	// NOTE
+	// . checked after the insert, a `Close`
+	//   that was not seen before it either
+	//   drains `chunk` or is seen here.
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		lfp.drain(index)
+	}
+}
+
+// insert pushes `chunk` to the shared chain of
+// class `index`.
+func (lfp *LFPool) insert(index int, chunk []byte) {
+	lfp.slots[index].insert(unsafe.Pointer(&chunk))
+}
+
+// entry returns the head segment of class
+// `index`.
+func (lfp *LFPool) entry(index int) *lfslice {
+	// NOTE
+	// . indexes directly, `ldSlot` moves its
+	//   cursor to the heap on every call.
+	return (*lfslice)(lfp.slots[index].ldEntry())
+}
+
+// reserve accounts one more retained buffer
+// of class `index`. It fails when the class
+// or the pool would exceed its cap.
+func (lfp *LFPool) reserve(index int) bool {
+	var (
+		ps   *pcount = &lfp.counts[index]
+		size int64   = int64(lfp.classes.size(index))
+	)
+	count, ok := ps.enter(lfp.caps[index])
+	if !ok {
+		return false
+	}
+	bytes := atomic.AddInt64(&lfp.retained, size)
+	if lfp.opts.MaxRetainedBytes > 0 && bytes > lfp.opts.MaxRetainedBytes {
+		atomic.AddInt64(&lfp.retained, -size)
+		atomic.AddInt64(&ps.count, -1)
+		return false
+	}
+	if lfp.stats != nil {
+		peak(&lfp.stats.blocks[index].max, count)
+		peak(&lfp.stats.peak, bytes)
+	}
+	return true
+}
+
+// unreserve accounts `n` buffers of class
+// `index` leaving the pool.
+func (lfp *LFPool) unreserve(index int, n int64) {
+	lfp.counts[index].leave(n, lfp.opts.TrimInterval > 0)
+	atomic.AddInt64(&lfp.retained, -n*int64(lfp.classes.size(index)))
+}
+
+// drop discards `chunk` of class `index`
+// instead of retaining it.
+func (lfp *LFPool) drop(index int, chunk []byte) {
+	lfp.discard(index, chunk)
+	if lfp.opts.Observer != nil {
+		lfp.opts.Observer.Drop(lfp.class(index), cap(chunk))
+	}
+}
+
+// discard is `drop` without the observer
+// event, for buffers reported in bulk.
+func (lfp *LFPool) discard(index int, chunk []byte) {
+	lfp.forget(chunk)
+	if lfp.stats != nil {
+		atomic.AddUint64(&lfp.stats.blocks[index].deallocs, 1)
+	}
+}
+
+// discarder returns `discard` for class
+// `index` over the pointers stored by
+// `Insert`.
+func (lfp *LFPool) discarder(index int) func(unsafe.Pointer) {
+	return func(p unsafe.Pointer) {
+		lfp.discard(index, *(*[]byte)(p))
+	}
+}
+
+// forget deletes the records of `chunk`, and
+// unmaps it when off-heap, as the pool lets
+// go of it for good.
+func (lfp *LFPool) forget(chunk []byte) {
+	var base uintptr = uintptr(unsafe.Pointer(&chunk[:1][0]))
+	if lfp.opts.TrackOwnership {
+		lfp.owners.Delete(base)
+	}
+	if lfp.opts.Debug {
+		lfp.poisons.Delete(base)
+	}
+	if lfp.opts.OffHeapSize > 0 {
+		if v, ok := lfp.mmaps.LoadAndDelete(base); ok {
+			munmapChunk(unsafe.Slice(unsafe.SliceData(chunk), v.(int)))
+		}
+	}
 }
 
 func (lfp *LFPool) ldSlot(index int, size uintptr) unsafe.Pointer {
 	var (
 		bin     *unsafe.Pointer
 		slotPtr unsafe.Pointer
-		nptr    unsafe.Pointer = unsafe.Pointer(&lfp.slots)
+		nptr    unsafe.Pointer = unsafe.Pointer(&lfp.slots[0])
 	)
 	bin = (*unsafe.Pointer)(unsafe.Pointer(uintptr(nptr) + (size * uintptr(index))))
 	slotPtr = atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&bin)))
@@ -439,17 +927,18 @@ func (lfs *lfslice) setNext(val unsafe.Pointer) bool {
 	)
 }
 
-func (lfs *lfslice) insert(bd []byte) bool {
+func (lfs *lfslice) insert(p unsafe.Pointer) bool {
 	var (
 		i    uint32
-		addr unsafe.Pointer = unsafe.Pointer(&lfs.data)
+		size uint32         = uint32(len(lfs.data))
+		addr unsafe.Pointer = unsafe.Pointer(&lfs.data[0])
 	)
 	for {
-		for i = cMin32; i < cLFSize; i++ {
-			if atomic.LoadUint32(&lfs.count) == cLFSize {
+		for i = cMin32; i < size; i++ {
+			if atomic.LoadUint32(&lfs.count) == size {
 				return false
 			}
-			if ptrCAS(addr, unsafe.Pointer(&bd), nil, i) {
+			if ptrCAS(addr, p, nil, i) {
 				atomic.AddUint32(&lfs.count, 1)
 				return true
 			}
@@ -462,12 +951,19 @@ func (lfs *lfslice) Len() uint32 {
 }
 
 func (lfs *lfslice) Insert(bd []byte) bool {
+	return lfs.push(unsafe.Pointer(&bd))
+}
+
+// push stores the non-nil `p`, appending a
+// segment to the chain when `lfs` is full.
+func (lfs *lfslice) push(p unsafe.Pointer) bool {
 	var (
 		n    unsafe.Pointer
 		nslc *markedPtr
+		size uint32 = uint32(len(lfs.data))
 	)
 	for {
-		if atomic.LoadUint32(&lfs.count) == cLFSize {
+		if atomic.LoadUint32(&lfs.count) == size {
 			n = atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&lfs.next)))
 			if !atomic.CompareAndSwapPointer(
 				(*unsafe.Pointer)(unsafe.Pointer(&lfs.next)),
@@ -477,7 +973,7 @@ func (lfs *lfslice) Insert(bd []byte) bool {
 				continue
 			}
 			if (*markedPtr)(n) == nil {
-				nslc = &markedPtr{unsafe.Pointer(newlfslice()), 0}
+				nslc = &markedPtr{unsafe.Pointer(newlfsliceSize(size)), 0}
 			} else {
 				nslc = (*markedPtr)(n)
 			}
@@ -486,10 +982,10 @@ func (lfs *lfslice) Insert(bd []byte) bool {
 				(unsafe.Pointer)(n),
 				(unsafe.Pointer)(nslc),
 			) {
-				return ((*lfslice)(nslc.next)).Insert(bd)
+				return ((*lfslice)(nslc.next)).push(p)
 			}
 		} else {
-			if lfs.insert(bd) {
+			if lfs.insert(p) {
 				return true
 			}
 		}
@@ -497,22 +993,22 @@ func (lfs *lfslice) Insert(bd []byte) bool {
 	}
 }
 
-func (lfs *lfslice) get() []byte {
+func (lfs *lfslice) get() unsafe.Pointer {
 	var (
 		i      uint32
-		v      *[]byte
 		target *unsafe.Pointer
 		vptr   unsafe.Pointer
-		addr   unsafe.Pointer = unsafe.Pointer(&lfs.data)
+		size   uint32         = uint32(len(lfs.data))
+		addr   unsafe.Pointer = unsafe.Pointer(&lfs.data[0])
 	)
 	for {
-		for i = cMin32; i < cLFSize; i++ {
+		for i = cMin32; i < size; i++ {
 			if atomic.LoadUint32(&lfs.count) == 0 {
 				return nil
 			}
 			target = (*unsafe.Pointer)(unsafe.Pointer((uintptr)(addr) + (ptrSize * uintptr(i))))
 			vptr = atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(target)))
-			if (*[]byte)(vptr) == nil {
+			if vptr == nil {
 				continue
 			}
 			// prevent overlapping parallel calls
@@ -520,19 +1016,56 @@ func (lfs *lfslice) get() []byte {
 				continue
 			}
 			if ptrCAS(addr, nil, vptr, i) {
-				v = (*[]byte)(vptr)
-				if v != nil {
-					atomic.AddUint32(&lfs.count, ^uint32(0))
-					return (*v)
-				}
-				return nil
+				atomic.AddUint32(&lfs.count, ^uint32(0))
+				return vptr
 			}
 		}
 		runtime.Gosched()
 	}
 }
 
+// drain pops up to `max` pointers, or all of
+// them when `max` is negative, handing each
+// to `drop` when not nil. It returns their
+// number.
+func (lfs *lfslice) drain(max int64, drop func(unsafe.Pointer)) int64 {
+	var n int64
+	for ; max < 0 || n < max; n++ {
+		p := lfs.pop()
+		if p == nil {
+			break
+		}
+		if drop != nil {
+			drop(p)
+		}
+	}
+	return n
+}
+
+// kill empties the detached chain `lfs`, see
+// `drain`.
+func (lfs *lfslice) kill(drop func(unsafe.Pointer)) int64 {
+	// NOTE
+	// . marked before it is emptied, a late
+	//   `pslot.insert` either sees the mark or
+	//   its entry is popped below.
+	atomic.StoreUint32(&lfs.dead, 1)
+	return lfs.drain(-1, drop)
+}
+
 func (lfs *lfslice) Get() []byte {
+	if v := lfs.pop(); v != nil {
+		return *(*[]byte)(v)
+	}
+	return nil
+}
+
+// pop removes a pointer stored by `push`,
+// following the chain when `lfs` is empty,
+// or emptied by a concurrent `pop`. It
+// returns nil once every segment was found
+// empty.
+func (lfs *lfslice) pop() unsafe.Pointer {
 	var (
 		n    unsafe.Pointer
 		nslc *markedPtr
@@ -553,13 +1086,13 @@ func (lfs *lfslice) Get() []byte {
 			}
 			if atomic.CompareAndSwapPointer(
 				(*unsafe.Pointer)(unsafe.Pointer(&lfs.next)),
-				(unsafe.Pointer)(lfs.next),
+				(unsafe.Pointer)(n),
 				(unsafe.Pointer)(nslc),
 			) {
-				return ((*lfslice)(nslc.next)).Get()
+				return ((*lfslice)(nslc.next)).pop()
 			}
-		} else {
-			return lfs.get()
+		} else if v := lfs.get(); v != nil {
+			return v
 		}
 	}
 }
@@ -602,19 +1135,25 @@ func (b *Buffer) Len() int {
 }
 
 func (b *Buffer) SetString(data string) {
-	b.Data = append(b.Data[:0], data...)
+	b.Data = b.Data[:0]
+	b.grow(len(data))
+	b.Data = append(b.Data, data...)
 }
 
 func (b *Buffer) Set(p []byte) {
-	b.Data = append(b.Data[:0], p...)
+	b.Data = b.Data[:0]
+	b.grow(len(p))
+	b.Data = append(b.Data, p...)
 }
 
 func (b *Buffer) Write(p []byte) (int, error) {
+	b.grow(len(p))
 	b.Data = append(b.Data, p...)
 	return len(p), nil
 }
 
 func (b *Buffer) WriteString(s string) (int, error) {
+	b.grow(len(s))
 	b.Data = append(b.Data, s...)
 	return len(s), nil
 }
@@ -625,34 +1164,21 @@ func (b *Buffer) WriteTo(writer io.Writer) (int64, error) {
 }
 
 func (b *Buffer) WriteByte(c byte) error {
+	b.grow(1)
 	b.Data = append(b.Data, c)
 	return nil
 }
 
 func (b *Buffer) ReadFrom(reader io.Reader) (int64, error) {
-	var (
-		buff []byte = b.Data
-		s, e int64  = int64(len(buff)), int64(cap(buff))
-		n    int64  = s
-	)
-	if e == 0 {
-		e = 64
-		buff = make([]byte, e)
-	} else {
-		buff = buff[:e]
-	}
+	var s int64 = int64(len(b.Data))
 	for {
-		if n == e {
-			e *= 2
-			nb := make([]byte, e)
-			copy(nb, buff)
-			buff = nb
-		}
-		nr, err := reader.Read(buff[n:])
-		n += int64(nr)
+		if len(b.Data) == cap(b.Data) {
+			b.grow(64)
+		}
+		nr, err := reader.Read(b.Data[len(b.Data):cap(b.Data)])
+		b.Data = b.Data[:len(b.Data)+nr]
 		if err != nil {
-			b.Data = buff[:n]
-			n -= s
+			n := int64(len(b.Data)) - s
 			if err == io.EOF {
 				return n, nil
 			}
@@ -661,6 +1187,40 @@ func (b *Buffer) ReadFrom(reader io.Reader) (int64, error) {
 	}
 }
 
+// grow makes room for `n` more bytes. The
+// new backing array comes from the pool and
+// the previous one goes back to it, so that
+// growth never leaves a pooled buffer, or its
+// mapping, to the GC. The capacity at least
+// doubles.
+func (b *Buffer) grow(n int) {
+	var (
+		length int = len(b.Data)
+		want   int = length + n
+		data   []byte
+	)
+	if want <= cap(b.Data) {
+		return
+	}
+	if want < 2*cap(b.Data) {
+		want = 2 * cap(b.Data)
+	}
+	if b.mp == nil || (want > b.mp.opts.MaxSize && b.mp.opts.Oversize == OversizeError) {
+		data = make([]byte, length, want)
+	} else {
+		data = b.mp.Get(length, want)
+	}
+	copy(data, b.Data)
+	if b.mp != nil && cap(b.Data) > 0 {
+		if b.auto {
+			b.mp.AutoRelease(b.Data)
+		} else {
+			b.mp.Release(b.Data)
+		}
+	}
+	b.Data = data
+}
+
 func (b *Buffer) String() string {
 	return string(b.Data)
 }
@@ -681,7 +1241,7 @@ func (s *Stats) adapt() {
 	)
 	for i := uint64(0); i < blklen; i++ {
 		allocs := atomic.SwapUint64(&s.blocks[i].rels, 0)
-		var size uint64 = 64 << i
+		var size uint64 = uint64(s.sizes[i])
 		if min == -1 || int64(size) < min {
 			min = int64(size)
 		}
@@ -691,7 +1251,7 @@ func (s *Stats) adapt() {
 			size:   size,
 		})
 	}
-	max, smax, sum = uint64(min), uint64(float64(sum)*percentile), 0
+	max, smax, sum = uint64(min), uint64(float64(sum)*s.percentile), 0
 	for i := 0; i < steps; i++ {
 		if sum < smax {
 			sum += n[i].allocs
@@ -704,6 +1264,6 @@ func (s *Stats) adapt() {
 		break
 	}
 	atomic.StoreUint64(&s.defbs, uint64(min))
-	atomic.StoreUint64(&s.max, maxSize)
+	atomic.StoreUint64(&s.max, s.maxsz)
 	atomic.StoreUint32(&s.safe, 0)
 }
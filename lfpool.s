@@ -0,0 +1,2 @@
+// This file is intentionally empty. It allows
+// bodyless declarations, see local.go.
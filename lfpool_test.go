@@ -25,8 +25,10 @@
 package lfpool
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -278,3 +280,188 @@ func TestExpH(t *testing.T) {
 		t.Fatal("invalid", cc, cl)
 	}
 }
+
+func TestOptions(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithMinSize(256), WithMaxSize(4096), WithSegmentSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cap(bp.Get(8)); c != 256 {
+		t.Fatal("invalid capacity", c)
+	}
+	if c := cap(bp.Get(1 << 20)); c != 1<<20 {
+		t.Fatal("invalid capacity", c)
+	}
+	for i := 0; i < 64; i++ {
+		bp.Release(make([]byte, 512))
+	}
+	if n := (*lfslice)(bp.slots[blocks.lgb2(512)].ldEntry()).Len(); n != 4 {
+		t.Fatal("invalid segment length", n)
+	}
+	bp.Release(make([]byte, 8192))
+	if n := (*lfslice)(bp.slots[blocks.lgb2(8192)].ldEntry()).Len(); n != 0 {
+		t.Fatal("retained buffer above MaxSize", n)
+	}
+	invalid := [][]Option{
+		{WithMinSize(100)},
+		{WithMaxSize(blocks[len(blocks)-1] * 2)},
+		{WithOversize(OversizeError, 1)},
+		{WithMinSize(4096), WithMaxSize(256)},
+		{WithSegmentSize(0)},
+		{WithAuto(true, 0, 0)},
+		{WithStats(true), WithAuto(true, 1.5, 0)},
+	}
+	for i, opts := range invalid {
+		if _, err := NewLFPoolWithOptions(opts...); err == nil {
+			t.Fatal("expected error", i)
+		} else if _, ok := err.(*OptionError); !ok {
+			t.Fatal("invalid error type", i, err)
+		}
+	}
+	bp, err = NewLFPoolWithOptions(WithStats(true), WithAuto(true, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bp.AutoGet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(
+		WithStats(true),
+		WithMaxRetained(4),
+		WithClassRetained(1024, 2),
+		WithMaxRetainedBytes(4096+2*1024+512),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		bp.Release(make([]byte, 1024))
+		bp.Release(make([]byte, 2048))
+		bp.Release(make([]byte, 512))
+	}
+	var (
+		c512  = blocks.lgb2(512)
+		c1024 = blocks.lgb2(1024)
+		c2048 = blocks.lgb2(2048)
+	)
+	if n := atomic.LoadInt64(&bp.counts[c1024].count); n != 2 {
+		t.Fatal("invalid retained count", n)
+	}
+	if n := atomic.LoadInt64(&bp.counts[c2048].count); n != 2 {
+		t.Fatal("invalid retained count", n)
+	}
+	if n := atomic.LoadInt64(&bp.counts[c512].count); n != 1 {
+		t.Fatal("invalid retained count", n)
+	}
+	if n := atomic.LoadInt64(&bp.retained); n != 4096+2*1024+512 {
+		t.Fatal("invalid retained bytes", n)
+	}
+	if n := atomic.LoadUint64(&bp.stats.blocks[c1024].deallocs); n != 6 {
+		t.Fatal("invalid deallocs", n)
+	}
+	bp.Get(1024)
+	bp.Get(1024)
+	if n := atomic.LoadInt64(&bp.counts[c1024].count); n != 0 {
+		t.Fatal("invalid retained count", n)
+	}
+	bp.Release(make([]byte, 1024))
+	if n := atomic.LoadInt64(&bp.counts[c1024].count); n != 1 {
+		t.Fatal("invalid retained count", n)
+	}
+	if _, err := NewLFPoolWithOptions(WithClassRetained(1000, 1)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestRetentionDetach releases buffers while
+// their class is detached, no reservation must
+// outlive the buffers once it is empty.
+func TestRetentionDetach(t *testing.T) {
+	const size = 64 << 10
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	bp, err := NewLFPoolWithOptions(WithStats(true), WithZero(ZeroOnRelease), WithLocalCacheSize(0), WithDebug(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg   sync.WaitGroup
+		stop = make(chan struct{})
+	)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					bp.Release(bp.Get(size))
+				}
+			}
+		}()
+	}
+	for i := 0; i < 2000; i++ {
+		bp.Drain(size)
+	}
+	close(stop)
+	wg.Wait()
+	bp.Drain(size)
+	index := bp.classes.index(size)
+	if n := atomic.LoadInt64(&bp.counts[index].count); n != 0 {
+		t.Fatal("leaked reservations", n)
+	}
+	if n := atomic.LoadInt64(&bp.retained); n != 0 {
+		t.Fatal("leaked retained bytes", n)
+	}
+}
+
+func TestTryGetRelease(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithOwnership(true), WithDebug(false), WithMaxSize(1<<20), WithOversize(OversizeError, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bp.TryGet(10, 5); !errors.Is(err, ErrInvalidSize) {
+		t.Fatal("expected ErrInvalidSize", err)
+	}
+	if _, err := bp.TryGet(-1, 5); !errors.Is(err, ErrInvalidSize) {
+		t.Fatal("expected ErrInvalidSize", err)
+	}
+	if _, err := bp.TryGet(0, 2<<20); !errors.Is(err, ErrTooLarge) {
+		t.Fatal("expected ErrTooLarge", err)
+	}
+	b, err := bp.TryGet(10, 100)
+	if err != nil || len(b) != 10 || cap(b) != 128 {
+		t.Fatal("invalid buffer", err, len(b), cap(b))
+	}
+	if err := bp.TryRelease(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.TryRelease(b); !errors.Is(err, ErrDoubleRelease) {
+		t.Fatal("expected ErrDoubleRelease", err)
+	}
+	if err := bp.TryRelease(make([]byte, 128)); !errors.Is(err, ErrForeign) {
+		t.Fatal("expected ErrForeign", err)
+	}
+	var rerr *ReleaseError
+	if err := bp.TryRelease(make([]byte, 128)); !errors.As(err, &rerr) || rerr.Kind != ForeignRelease {
+		t.Fatal("expected *ReleaseError", err)
+	}
+	bp, _ = NewLFPoolWithOptions(WithMaxSize(1<<20), WithOversize(OversizeError, 0))
+	if err := bp.TryRelease(make([]byte, 8)); !errors.Is(err, ErrInvalidSize) {
+		t.Fatal("expected ErrInvalidSize", err)
+	}
+	if err := bp.TryRelease(make([]byte, 2<<20)); !errors.Is(err, ErrTooLarge) {
+		t.Fatal("expected ErrTooLarge", err)
+	}
+	bp.Close()
+	if _, err := bp.TryGet(1, 1); !errors.Is(err, ErrClosed) {
+		t.Fatal("expected ErrClosed", err)
+	}
+	if err := bp.TryRelease(make([]byte, 64)); !errors.Is(err, ErrClosed) {
+		t.Fatal("expected ErrClosed", err)
+	}
+}
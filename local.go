@@ -0,0 +1,154 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// - MARK: local cache section.
+
+const (
+	cLocalSize = 8
+	cLocalMax  = 256
+	cCacheLine = 128
+)
+
+// lcache is a small per-P free list of a
+// single class. It is guarded by a try-lock,
+// a contended cache is bypassed instead of
+// waited on.
+type lcache struct {
+	lock  uint32
+	items [][]byte
+}
+
+// plocal holds the caches of one P, padded
+// to avoid false sharing with its neighbours.
+type plocal struct {
+	caches []lcache
+	_      [cCacheLine - unsafe.Sizeof([]lcache{})]byte
+}
+
+//go:linkname runtime_procPin runtime.procPin
+func runtime_procPin() int
+
+//go:linkname runtime_procUnpin runtime.procUnpin
+func runtime_procUnpin()
+
+// newLocals allocates one `plocal` per P
+// with `nclass` caches of `size` entries.
+func newLocals(nclass int, size int) []plocal {
+	var locals []plocal = make([]plocal, runtime.GOMAXPROCS(0))
+	for i := range locals {
+		locals[i].caches = make([]lcache, nclass)
+		for j := range locals[i].caches {
+			locals[i].caches[j].items = make([][]byte, 0, size)
+		}
+	}
+	return locals
+}
+
+// local returns the cache of class `index`
+// owned by the current P.
+func (lfp *LFPool) local(index int) *lcache {
+	pid := runtime_procPin()
+	runtime_procUnpin()
+	return &lfp.locals[pid%len(lfp.locals)].caches[index]
+}
+
+// getLocal pops a buffer from the local cache
+// of class `index`, refilling it with a batch
+// from the shared chain when it is empty.
+func (lfp *LFPool) getLocal(index int, entry *lfslice) []byte {
+	var (
+		c   *lcache = lfp.local(index)
+		ret []byte
+	)
+	if !atomic.CompareAndSwapUint32(&c.lock, 0, 1) {
+		return entry.Get()
+	}
+	if len(c.items) == 0 {
+		for i := 0; i < (cap(c.items)+1)/2; i++ {
+			chunk := entry.Get()
+			if chunk == nil {
+				break
+			}
+			c.items = append(c.items, chunk)
+		}
+	}
+	if n := len(c.items); n > 0 {
+		ret = c.items[n-1]
+		c.items[n-1] = nil
+		c.items = c.items[:n-1]
+	}
+	atomic.StoreUint32(&c.lock, 0)
+	return ret
+}
+
+// putLocal pushes `chunk` to the local cache
+// of class `index`, spilling half of the
+// cache to the shared chain when it is full.
+func (lfp *LFPool) putLocal(index int, chunk []byte) {
+	var (
+		c *lcache = lfp.local(index)
+	)
+	if !atomic.CompareAndSwapUint32(&c.lock, 0, 1) {
+		lfp.insert(index, chunk)
+		return
+	}
+	if n := len(c.items); n == cap(c.items) {
+		for i := n / 2; i < n; i++ {
+			lfp.insert(index, c.items[i])
+			c.items[i] = nil
+		}
+		c.items = c.items[:n/2]
+	}
+	c.items = append(c.items, chunk)
+	atomic.StoreUint32(&c.lock, 0)
+}
+
+// drainLocal pops up to `max` buffers of class
+// `index` from every local cache, or all of
+// them when `max` is negative, and passes
+// them to `fn`. Accounting is left to the
+// caller.
+func (lfp *LFPool) drainLocal(index int, max int64, fn func([]byte)) int64 {
+	var n int64
+	for i := range lfp.locals {
+		c := &lfp.locals[i].caches[index]
+		for !atomic.CompareAndSwapUint32(&c.lock, 0, 1) {
+			runtime.Gosched()
+		}
+		for k := len(c.items); k > 0 && (max < 0 || n < max); k-- {
+			fn(c.items[k-1])
+			c.items[k-1] = nil
+			c.items = c.items[:k-1]
+			n++
+		}
+		atomic.StoreUint32(&c.lock, 0)
+	}
+	return n
+}
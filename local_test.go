@@ -0,0 +1,96 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestLocalCache(t *testing.T) {
+	var (
+		wg sync.WaitGroup
+	)
+	bp, err := NewLFPoolWithOptions(WithLocalCacheSize(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			held := make([][]byte, 0, 16)
+			for j := 0; j < 1000; j++ {
+				held = append(held, bp.Get(1024))
+				if len(held) == cap(held) {
+					for _, h := range held {
+						bp.Release(h)
+					}
+					held = held[:0]
+				}
+			}
+			for _, h := range held {
+				bp.Release(h)
+			}
+		}()
+	}
+	wg.Wait()
+	// every buffer must be handed out to a single
+	// holder at a time.
+	owners := make(map[*byte]bool)
+	held := make([][]byte, 0, 4096)
+	for i := 0; i < 4096; i++ {
+		b := bp.Get(1024)
+		if owners[&b[0]] {
+			t.Fatal("buffer handed out twice")
+		}
+		owners[&b[0]] = true
+		held = append(held, b)
+	}
+}
+
+func benchmarkGetRelease(b *testing.B, local int) {
+	bp, err := NewLFPoolWithOptions(WithLocalCacheSize(local))
+	if err != nil {
+		b.Fatal(err)
+	}
+	// at least 32 goroutines.
+	b.SetParallelism((32 + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			bp.Release(bp.Get(4096))
+		}
+	})
+}
+
+func BenchmarkGetReleaseShared(b *testing.B) {
+	benchmarkGetRelease(b, 0)
+}
+
+func BenchmarkGetReleaseLocal(b *testing.B) {
+	benchmarkGetRelease(b, cLocalSize)
+}
@@ -0,0 +1,172 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"sync/atomic"
+)
+
+// - MARK: memory limit section.
+
+const (
+	// CgroupMemoryMax is the usual location of
+	// the cgroup v2 memory limit.
+	CgroupMemoryMax = "/sys/fs/cgroup/memory.max"
+
+	cHighMark      = 0.9
+	cLowMark       = 0.8
+	cWatchInterval = 1e9 // 1s
+)
+
+var memSamples = []metrics.Sample{
+	{Name: "/memory/classes/total:bytes"},
+	{Name: "/memory/classes/heap/released:bytes"},
+}
+
+// Shrink drops retained buffers, oversized
+// ones then largest classes first, until at
+// least `target` bytes are freed or the pool
+// is empty. It returns the freed bytes.
+func (lfp *LFPool) Shrink(target int64) int64 {
+	var freed int64
+	defer endRegion(lfp.region("lfpool.shrink"))
+	if target > 0 {
+		freed = lfp.flushHuge(true)
+	}
+	for index := lfp.classes.hi; index >= lfp.classes.lo && freed < target; index-- {
+		freed += lfp.shrinkClass(index, target-freed)
+	}
+	lfp.eachAligned(func(child *LFPool) {
+		if freed < target {
+			freed += child.Shrink(target - freed)
+		}
+	})
+	return freed
+}
+
+// shrinkClass drops buffers of class `index`,
+// oldest generation first, until `want`
+// bytes are freed.
+func (lfp *LFPool) shrinkClass(index int, want int64) int64 {
+	var (
+		size int64 = int64(lfp.classes.size(index))
+		n    int64
+	)
+	pop := func(head *lfslice) {
+		for n*size < want {
+			chunk := head.Get()
+			if chunk == nil {
+				return
+			}
+			lfp.drop(index, chunk)
+			n++
+		}
+	}
+	if lfp.victims != nil {
+		pop((*lfslice)(lfp.victims[index].ldEntry()))
+	}
+	pop(lfp.entry(index))
+	if n*size < want {
+		n += lfp.drainLocal(index, (want-n*size+size-1)/size, func(chunk []byte) {
+			lfp.drop(index, chunk)
+		})
+	}
+	if n > 0 && lfp.track {
+		lfp.unreserve(index, n)
+	}
+	return n * size
+}
+
+// watchMemory shrinks the pool once the memory
+// in use crosses the high watermark of the
+// effective limit and keeps shrinking until
+// it falls below the low watermark.
+func (lfp *LFPool) watchMemory() int64 {
+	var (
+		limit int64 = lfp.memoryLimit()
+		usage int64
+	)
+	if limit <= 0 {
+		atomic.StoreUint32(&lfp.shrink, 0)
+		return 0
+	}
+	usage = memoryUsage()
+	if usage >= int64(float64(limit)*lfp.opts.HighWatermark) {
+		atomic.StoreUint32(&lfp.shrink, 1)
+	}
+	low := int64(float64(limit) * lfp.opts.LowWatermark)
+	if usage <= low {
+		atomic.StoreUint32(&lfp.shrink, 0)
+	}
+	if atomic.LoadUint32(&lfp.shrink) == 0 {
+		return 0
+	}
+	return lfp.Shrink(usage - low)
+}
+
+// memoryLimit returns the smallest of the
+// enabled limits, or -1 when none is set.
+func (lfp *LFPool) memoryLimit() int64 {
+	var limit int64 = -1
+	if lfp.opts.MemoryLimit {
+		if l := debug.SetMemoryLimit(-1); l < math.MaxInt64 {
+			limit = l
+		}
+	}
+	if lfp.opts.CgroupPath != "" {
+		if l, err := readCgroupLimit(lfp.opts.CgroupPath); err == nil && l > 0 && (limit < 0 || l < limit) {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// memoryUsage returns the memory mapped by
+// the runtime minus what was returned to
+// the OS, as accounted by the memory limit.
+func memoryUsage() int64 {
+	var samples []metrics.Sample = make([]metrics.Sample, len(memSamples))
+	copy(samples, memSamples)
+	metrics.Read(samples)
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+// readCgroupLimit parses a cgroup v2 memory
+// limit file, "max" yields -1.
+func readCgroupLimit(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return -1, err
+	}
+	data = bytes.TrimSpace(data)
+	if string(data) == "max" {
+		return -1, nil
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
@@ -0,0 +1,89 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShrink(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		bp.Release(make([]byte, 1024))
+		bp.Release(make([]byte, 8192))
+	}
+	if n := bp.Shrink(10000); n != 2*8192 {
+		t.Fatal("invalid freed bytes", n)
+	}
+	if n := atomic.LoadInt64(&bp.retained); n != 4*1024+2*8192 {
+		t.Fatal("invalid retained bytes", n)
+	}
+	if n := bp.Shrink(1 << 30); n != 4*1024+2*8192 {
+		t.Fatal("invalid freed bytes", n)
+	}
+}
+
+func TestWatchMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.max")
+	if err := os.WriteFile(path, []byte("max\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bp, err := NewLFPoolWithOptions(WithMemoryLimit(false, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		bp.Release(make([]byte, 1<<20))
+	}
+	if n := bp.watchMemory(); n != 0 {
+		t.Fatal("unexpected shrink without limit", n)
+	}
+	// any process is above a 1 KiB limit.
+	if err := os.WriteFile(path, []byte("1024\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n := bp.watchMemory(); n != 4<<20 {
+		t.Fatal("invalid freed bytes", n)
+	}
+	if atomic.LoadUint32(&bp.shrink) != 1 {
+		t.Fatal("expected shrinking state")
+	}
+	if err := os.WriteFile(path, []byte("max\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bp.watchMemory()
+	if atomic.LoadUint32(&bp.shrink) != 0 {
+		t.Fatal("expected idle state")
+	}
+	if _, err := NewLFPoolWithOptions(WithWatermarks(0.5, 0.7)); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,85 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync/atomic"
+)
+
+// - MARK: misfit section.
+
+// MisfitPolicy tells how releases whose
+// capacity is not a class size are retained.
+type MisfitPolicy int
+
+const (
+	// MisfitCopy copies the buffer into a new
+	// one of the class above, so that no
+	// memory is wasted but the release costs
+	// an allocation.
+	MisfitCopy MisfitPolicy = iota
+	// MisfitRoundDown retains the buffer in
+	// the largest class it fits, trimming its
+	// capacity.
+	MisfitRoundDown
+	// MisfitDrop leaves the buffer to the GC.
+	MisfitDrop
+)
+
+// place fits `chunk`, whose capacity is below
+// the size of class `index`, as set by
+// `Misfit`. It returns the class and the
+// buffer to retain, or false to drop it.
+func (lfp *LFPool) place(index int, chunk []byte) (int, []byte, bool) {
+	switch lfp.opts.Misfit {
+	case MisfitRoundDown:
+		// NOTE
+		// . `index` is above `lo`, the smallest
+		//   class is `MinSize` and `chunk` holds
+		//   at least as much.
+		index--
+		size := lfp.classes.size(index)
+		chunk = chunk[:size:size]
+		if lfp.stats != nil {
+			atomic.AddUint64(&lfp.stats.blocks[index].rounds, 1)
+		}
+	case MisfitDrop:
+		if lfp.stats != nil {
+			atomic.AddUint64(&lfp.stats.blocks[index].discards, 1)
+		}
+		if lfp.opts.Observer != nil {
+			lfp.opts.Observer.Drop(lfp.class(index), cap(chunk))
+		}
+		lfp.forget(chunk)
+		return index, nil, false
+	default:
+		ctmp := lfp.alloc(index)
+		copy(ctmp, chunk)
+		lfp.forget(chunk)
+		chunk = ctmp
+		if lfp.stats != nil {
+			atomic.AddUint64(&lfp.stats.blocks[index].copies, 1)
+		}
+	}
+	return index, chunk, true
+}
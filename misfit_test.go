@@ -0,0 +1,69 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestMisfit(t *testing.T) {
+	var c512, c1024 = blocks.lgb2(512), blocks.lgb2(1024)
+	for _, policy := range []MisfitPolicy{MisfitCopy, MisfitRoundDown, MisfitDrop} {
+		bp, err := NewLFPoolWithOptions(WithMisfit(policy), WithStats(true), WithDebug(false), WithLocalCacheSize(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 1000)
+		bp.Release(b)
+		s := bp.stats.blocks
+		switch policy {
+		case MisfitCopy:
+			if c := bp.Get(1024); cap(c) != 1024 || &c[0] == &b[0] {
+				t.Fatal("expected a copy")
+			}
+			if n := atomic.LoadUint64(&s[c1024].copies); n != 1 {
+				t.Fatal("invalid copies", n)
+			}
+		case MisfitRoundDown:
+			if c := bp.Get(512); cap(c) != 512 || &c[0] != &b[0] {
+				t.Fatal("expected the rounded down buffer")
+			}
+			if n := atomic.LoadUint64(&s[c512].rounds); n != 1 {
+				t.Fatal("invalid rounds", n)
+			}
+		case MisfitDrop:
+			if n := (*lfslice)(bp.slots[c1024].ldEntry()).Len() + (*lfslice)(bp.slots[c512].ldEntry()).Len(); n != 0 {
+				t.Fatal("retained a misfit buffer", n)
+			}
+			if n := atomic.LoadUint64(&s[c1024].discards); n != 1 {
+				t.Fatal("invalid discards", n)
+			}
+		}
+	}
+	if _, err := NewLFPoolWithOptions(WithMisfit(MisfitDrop + 1)); err == nil {
+		t.Fatal("expected error")
+	}
+}
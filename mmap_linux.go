@@ -0,0 +1,63 @@
+//go:build linux
+
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"syscall"
+)
+
+// - MARK: mmap section.
+
+const (
+	cHugePage = 2 << 20
+)
+
+// mmapChunk maps `size` bytes of anonymous
+// memory, hinting huge pages when `huge`
+// is set.
+func mmapChunk(size int, huge bool) ([]byte, error) {
+	chunk, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if huge {
+		// best effort, THP may be disabled.
+		_ = syscall.Madvise(chunk, syscall.MADV_HUGEPAGE)
+	}
+	return chunk, nil
+}
+
+// munmapChunk releases a mapping returned by
+// `mmapChunk`.
+func munmapChunk(chunk []byte) {
+	_ = syscall.Munmap(chunk[:cap(chunk)])
+}
+
+// adviseZero drops the pages of a mapping
+// returned by `mmapChunk`, the kernel maps
+// zero pages back on the next access.
+func adviseZero(chunk []byte) bool {
+	return syscall.Madvise(chunk[:cap(chunk)], syscall.MADV_DONTNEED) == nil
+}
@@ -0,0 +1,110 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+func TestOffHeap(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithOffHeap(1<<20, true), WithLocalCacheSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.Get(2 << 20)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	_, mapped := bp.mmaps.Load(uintptr(unsafe.Pointer(&b[0])))
+	if mapped != (runtime.GOOS == "linux") {
+		t.Fatal("invalid mapping state", mapped)
+	}
+	if c := bp.Get(4096); func() bool { _, ok := bp.mmaps.Load(uintptr(unsafe.Pointer(&c[0]))); return ok }() {
+		t.Fatal("small class must stay on the heap")
+	}
+	bp.Release(b)
+	if c := bp.Get(2 << 20); &c[0] != &b[0] {
+		t.Fatal("expected reuse")
+	} else {
+		bp.Release(c)
+	}
+	if n := bp.Shrink(1); n != 2<<20 {
+		t.Fatal("invalid freed bytes", n)
+	}
+	if _, ok := bp.mmaps.Load(uintptr(unsafe.Pointer(&b[0]))); ok {
+		t.Fatal("mapping not released")
+	}
+}
+
+func TestOffHeapClose(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithOffHeap(1<<20, false), WithOwnership(true), WithExactCap(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := bp.Get(2<<20), bp.Get(1<<20+100)
+	bp.Close()
+	if err := bp.TryRelease(a); err != ErrClosed {
+		t.Fatal("invalid error", err)
+	}
+	bp.Release(b)
+	count := 0
+	bp.mmaps.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	bp.owners.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	if count != 0 {
+		t.Fatal("records kept after close", count)
+	}
+}
+
+func TestOffHeapBufferGrow(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithOffHeap(1<<20, false), WithLocalCacheSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.GetBuffer(1 << 20)
+	base := &b.Data[:1][0]
+	data := bytes.Repeat([]byte{7}, 3<<20)
+	if _, err := b.Write(data[:1<<20]); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := b.ReadFrom(bytes.NewReader(data[1<<20:])); err != nil || n != 2<<20 {
+		t.Fatal("invalid read", n, err)
+	}
+	if !bytes.Equal(b.Data, data) {
+		t.Fatal("invalid contents")
+	}
+	if c := bp.Get(1 << 20); &c[0] != base {
+		t.Fatal("outgrown buffer not handed back")
+	}
+	b.Release()
+}
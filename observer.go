@@ -0,0 +1,96 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// - MARK: observer section.
+
+const (
+	cClassHuge = -1 // class index reported for oversized buffers
+)
+
+// Observer receives the events of a pool.
+// `class` is the class index, in the order of
+// `Classes`, or -1 for oversized buffers, and
+// `size` the capacity of the buffers involved.
+// Callbacks run inline on the hot path and
+// must be fast and safe for concurrent use.
+// Every dropped buffer is reported once, by
+// `Drop`, `Trim` or `Detach`. Embed
+// `NopObserver` to implement a subset.
+type Observer interface {
+	// Hit is a `Get` served by a pooled buffer.
+	Hit(class int, size int)
+	// Miss is a `Get` served by a new buffer.
+	Miss(class int, size int)
+	// Release is a buffer taken back.
+	Release(class int, size int)
+	// Drop is a buffer discarded by the pool
+	// on its own, e.g. beyond a retention cap.
+	Drop(class int, size int)
+	// Trim is a trimming pass dropping `n`
+	// idle buffers of a class.
+	Trim(class int, size int, n int64)
+	// Detach is a whole class of `n` buffers
+	// dropped at once, by a trimming pass
+	// over a class that was not reused, a
+	// drain or a GC cycle.
+	Detach(class int, size int, n int64)
+}
+
+// NopObserver implements `Observer` with no-op
+// callbacks.
+type NopObserver struct{}
+
+func (NopObserver) Hit(class int, size int)             {}
+func (NopObserver) Miss(class int, size int)            {}
+func (NopObserver) Release(class int, size int)         {}
+func (NopObserver) Drop(class int, size int)            {}
+func (NopObserver) Trim(class int, size int, n int64)   {}
+func (NopObserver) Detach(class int, size int, n int64) {}
+
+// class returns the index of class `index`
+// as reported to observers.
+func (lfp *LFPool) class(index int) int {
+	return index - lfp.classes.lo
+}
+
+// region starts a `runtime/trace` region named
+// `name` when `Trace` is set, it is ended by
+// `endRegion`.
+func (lfp *LFPool) region(name string) *trace.Region {
+	if !lfp.opts.Trace {
+		return nil
+	}
+	return trace.StartRegion(context.Background(), name)
+}
+
+func endRegion(r *trace.Region) {
+	if r != nil {
+		r.End()
+	}
+}
@@ -0,0 +1,129 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type countObserver struct {
+	NopObserver
+	mu     sync.Mutex
+	events map[string]int64
+}
+
+func (o *countObserver) add(name string, class int, n int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events[name] += n
+	if class >= 0 {
+		o.events["class"] = int64(class)
+	}
+}
+
+func (o *countObserver) Hit(class int, size int)     { o.add("hit", class, 1) }
+func (o *countObserver) Miss(class int, size int)    { o.add("miss", class, 1) }
+func (o *countObserver) Release(class int, size int) { o.add("release", class, 1) }
+func (o *countObserver) Drop(class int, size int)    { o.add("drop", class, 1) }
+func (o *countObserver) Trim(class int, size int, n int64) {
+	o.add("trim", class, n)
+}
+func (o *countObserver) Detach(class int, size int, n int64) {
+	o.add("detach", class, n)
+}
+
+func TestObserver(t *testing.T) {
+	obs := &countObserver{events: make(map[string]int64)}
+	bp, err := NewLFPoolWithOptions(
+		WithObserver(obs),
+		WithTrace(true),
+		WithLocalCacheSize(0),
+		WithMaxRetained(1),
+		WithMaxSize(1<<20),
+		WithTrim(time.Hour, 0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := bp.Get(100), bp.Get(100)
+	bp.Release(a)
+	bp.Release(b)
+	bp.Get(100)
+	bp.Release(bp.Get(2 << 20))
+	want := map[string]int64{"miss": 3, "hit": 1, "release": 3, "drop": 2, "class": 1}
+	for name, n := range want {
+		if obs.events[name] != n {
+			t.Fatal("invalid event count", name, obs.events[name], n)
+		}
+	}
+	if bp.Classes()[1] != 128 {
+		t.Fatal("invalid class", bp.Classes())
+	}
+	bp.Release(make([]byte, 256))
+	bp.Trim()
+	bp.Trim()
+	if obs.events["trim"] != 0 || obs.events["detach"] != 1 || obs.events["drop"] != 2 {
+		t.Fatal("invalid trim counts", obs.events)
+	}
+}
+
+func TestObserverDetach(t *testing.T) {
+	obs := &countObserver{events: make(map[string]int64)}
+	bp, err := NewLFPoolWithOptions(WithObserver(obs), WithLocalCacheSize(0), WithTrim(time.Hour, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		bp.Release(make([]byte, 1024))
+	}
+	bp.Trim()
+	bp.Release(bp.Get(1024))
+	bp.Trim()
+	if obs.events["trim"] != 2 || obs.events["detach"] != 0 {
+		t.Fatal("invalid partial trim counts", obs.events)
+	}
+	bp.Trim()
+	if obs.events["trim"] != 2 || obs.events["detach"] != 1 {
+		t.Fatal("invalid detaching trim counts", obs.events)
+	}
+	bp.Release(make([]byte, 1024))
+	bp.Release(make([]byte, 1024))
+	if n := bp.Drain(1024); n != 2048 {
+		t.Fatal("invalid drained bytes", n)
+	}
+	if obs.events["detach"] != 3 || obs.events["drop"] != 0 {
+		t.Fatal("invalid drain counts", obs.events)
+	}
+}
+
+func TestObserverNil(t *testing.T) {
+	bp, _ := NewLFPoolWithOptions(WithDebug(false))
+	bp.Release(bp.Get(100))
+	if n := testing.AllocsPerRun(100, func() {
+		bp.Release(bp.Get(100))
+	}); n != 0 {
+		t.Fatal("allocations without an observer", n)
+	}
+}
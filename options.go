@@ -0,0 +1,549 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"fmt"
+	"time"
+)
+
+// - MARK: Options section.
+
+const (
+	cSegMax = 1024
+)
+
+// Options holds the tunables of a `LFPool`.
+// The zero value is not usable, start from
+// `DefaultOptions` instead.
+type Options struct {
+	// MinSize is the smallest class size,
+	// it must be a power of two.
+	MinSize int
+	// MaxSize is the largest class size,
+	// it must be a power of two.
+	MaxSize int
+	// SegmentSize is the number of slots
+	// in every `lfslice` segment.
+	SegmentSize int
+	// Stats enables per class statistics.
+	Stats bool
+	// Auto enables `AutoGet` and friends,
+	// it requires `Stats`.
+	Auto bool
+	// Percentile is the share of releases
+	// used by auto mode to pick the
+	// retained range.
+	Percentile float64
+	// AutoMaxSize is the largest capacity
+	// retained by `AutoRelease`.
+	AutoMaxSize int
+	// Layout generates the class sizes,
+	// defaults to `PowerOfTwo`.
+	Layout Layout
+	// Classes are exact class sizes pinned
+	// on top of `Layout`, e.g. 1500 or 9000.
+	Classes []int
+	// Oversize tells how requests above
+	// `MaxSize` are served, defaults to
+	// exact size allocations.
+	Oversize OversizePolicy
+	// HugeRetained is the number of oversized
+	// buffers kept for reuse, zero disables
+	// the cache.
+	HugeRetained int
+	// ExactCap makes `Get(n)` return `b[:n:n]`,
+	// the class buffer is recovered on release
+	// of the view or of a sub-slice reaching
+	// its end. `Get(0)` returns an empty view
+	// holding no buffer. Views that are never
+	// released, or that `append` moved, are
+	// left to the GC.
+	ExactCap bool
+	// Misfit tells how releases whose capacity
+	// is not a class size are retained,
+	// defaults to copying them.
+	Misfit MisfitPolicy
+	// LocalCacheSize is the number of buffers
+	// kept per P and class in front of the
+	// shared segments, zero disables them.
+	LocalCacheSize int
+	// MaxRetained caps the number of buffers
+	// retained per class, zero means no cap.
+	MaxRetained int
+	// ClassRetained overrides `MaxRetained`
+	// for the class of the given capacity.
+	ClassRetained map[int]int
+	// MaxRetainedBytes caps the bytes retained
+	// by the whole pool, zero means no cap.
+	MaxRetainedBytes int64
+	// TrimInterval is the period of the
+	// janitor started by `Start`, zero
+	// disables trimming.
+	TrimInterval time.Duration
+	// IdleTimeout is the window after which
+	// buffers that were not reused are
+	// trimmed, it defaults to `TrimInterval`.
+	IdleTimeout time.Duration
+	// OnTrim, when set, receives the bytes
+	// freed by every janitor pass.
+	OnTrim func(freed int64)
+	// GCVictim moves retained buffers to a
+	// victim generation on every GC cycle,
+	// buffers left there for a second cycle
+	// are dropped.
+	GCVictim bool
+	// MemoryLimit makes the janitor shrink the
+	// pool when the heap gets close to the
+	// limit set by `debug.SetMemoryLimit`.
+	MemoryLimit bool
+	// CgroupPath is a cgroup v2 `memory.max`
+	// file checked the same way, empty
+	// disables it.
+	CgroupPath string
+	// HighWatermark is the share of the limit
+	// that starts shrinking.
+	HighWatermark float64
+	// LowWatermark is the share of the limit
+	// that stops shrinking.
+	LowWatermark float64
+	// OffHeapSize is the smallest class served
+	// by anonymous mappings instead of the Go
+	// heap, zero disables them. Only Linux
+	// supports it, other platforms fall back
+	// to the heap. A mapping is unmapped only
+	// once the pool drops it after getting it
+	// back under its original base address:
+	// mapped buffers that are never released,
+	// that `append` moved to the heap, or that
+	// are released as a sub-slice with another
+	// base leak their mapping for good. A
+	// reference kept after a release faults
+	// once the pool unmaps the buffer.
+	OffHeapSize int
+	// HugePages hints transparent huge pages
+	// for mapped classes of 2 MiB and more.
+	HugePages bool
+	// SlabSize is the size of the slabs that
+	// small classes are carved from, zero
+	// disables slabs.
+	SlabSize int
+	// SlabMaxSize is the largest class carved
+	// from slabs.
+	SlabMaxSize int
+	// Zero is the zeroing policy of pooled
+	// buffers.
+	Zero ZeroPolicy
+	// Debug poisons released buffers and
+	// panics when one was written to before
+	// being handed out again. It defaults to
+	// true with the `lfpool_debug` build tag.
+	Debug bool
+	// TrackOwnership records the buffers handed
+	// out by the pool to reject double and
+	// foreign releases.
+	TrackOwnership bool
+	// OnError, when set, receives the errors
+	// of calls that cannot return one, such as
+	// `Release`. Debug mode panics instead.
+	OnError func(error)
+	// LeakTracking records the call stack of
+	// outstanding buffers for `Outstanding`
+	// and `WriteLeakReport`, it requires
+	// `TrackOwnership`.
+	LeakTracking bool
+	// LeakSampleRate records one stack every
+	// `LeakSampleRate` buffers, it defaults
+	// to every buffer.
+	LeakSampleRate int
+	// ProfileName registers a `runtime/pprof`
+	// profile of that name listing the stacks
+	// that hold pooled buffers. The profile
+	// counts buffers, see `WriteLeakReport`
+	// for bytes. Profiles cannot be removed,
+	// the name must be unique per process.
+	ProfileName string
+	// Observer, when set, receives the events
+	// of the pool, see `Observer`.
+	Observer Observer
+	// Trace emits `runtime/trace` regions
+	// around allocations, trimming, shrinking
+	// and draining.
+	Trace bool
+}
+
+// Option mutates `Options` before they are
+// validated by `NewLFPoolWithOptions`.
+type Option func(*Options)
+
+// OptionError is returned by `NewLFPoolWithOptions`
+// when a field or a combination of fields
+// is invalid.
+type OptionError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+// DefaultOptions returns the options used
+// by `NewLFPool`.
+func DefaultOptions() Options {
+	return Options{
+		MinSize:     minSize,
+		MaxSize:     lBlkMax,
+		SegmentSize: cLFSize,
+		Percentile:  percentile,
+		AutoMaxSize: maxSize,
+		Layout:      PowerOfTwo(),
+
+		LocalCacheSize: cLocalSize,
+		HighWatermark:  cHighMark,
+		LowWatermark:   cLowMark,
+		Debug:          cDebug,
+	}
+}
+
+// WithOptions replaces all options with `o`.
+func WithOptions(o Options) Option {
+	return func(opts *Options) { *opts = o }
+}
+
+// WithMinSize sets the smallest class size.
+func WithMinSize(size int) Option {
+	return func(opts *Options) { opts.MinSize = size }
+}
+
+// WithMaxSize sets the largest class size.
+func WithMaxSize(size int) Option {
+	return func(opts *Options) { opts.MaxSize = size }
+}
+
+// WithSegmentSize sets the number of slots
+// per segment.
+func WithSegmentSize(size int) Option {
+	return func(opts *Options) { opts.SegmentSize = size }
+}
+
+// WithLayout sets the class layout.
+func WithLayout(layout Layout) Option {
+	return func(opts *Options) { opts.Layout = layout }
+}
+
+// WithClasses pins exact class sizes.
+func WithClasses(sizes ...int) Option {
+	return func(opts *Options) { opts.Classes = append(opts.Classes, sizes...) }
+}
+
+// WithOversize sets the policy for requests
+// above `MaxSize`, keeping up to `retained`
+// oversized buffers for reuse.
+func WithOversize(policy OversizePolicy, retained int) Option {
+	return func(opts *Options) {
+		opts.Oversize = policy
+		opts.HugeRetained = retained
+	}
+}
+
+// WithExactCap enables or disables exact
+// capacity views.
+func WithExactCap(enabled bool) Option {
+	return func(opts *Options) { opts.ExactCap = enabled }
+}
+
+// WithMisfit sets the policy for releases
+// whose capacity is not a class size.
+func WithMisfit(policy MisfitPolicy) Option {
+	return func(opts *Options) { opts.Misfit = policy }
+}
+
+// WithLocalCacheSize sets the number of
+// buffers cached per P and class.
+func WithLocalCacheSize(size int) Option {
+	return func(opts *Options) { opts.LocalCacheSize = size }
+}
+
+// WithMaxRetained caps the number of buffers
+// retained per class.
+func WithMaxRetained(count int) Option {
+	return func(opts *Options) { opts.MaxRetained = count }
+}
+
+// WithClassRetained caps the number of buffers
+// retained by the class of capacity `size`.
+func WithClassRetained(size int, count int) Option {
+	return func(opts *Options) {
+		if opts.ClassRetained == nil {
+			opts.ClassRetained = make(map[int]int)
+		}
+		opts.ClassRetained[size] = count
+	}
+}
+
+// WithMaxRetainedBytes caps the bytes retained
+// by the pool.
+func WithMaxRetainedBytes(size int64) Option {
+	return func(opts *Options) { opts.MaxRetainedBytes = size }
+}
+
+// WithTrim enables trimming of buffers that
+// were not reused within `idle`, checked
+// every `interval` once `Start` is called.
+func WithTrim(interval time.Duration, idle time.Duration) Option {
+	return func(opts *Options) {
+		opts.TrimInterval = interval
+		opts.IdleTimeout = idle
+	}
+}
+
+// WithOnTrim sets the janitor report callback.
+func WithOnTrim(fn func(freed int64)) Option {
+	return func(opts *Options) { opts.OnTrim = fn }
+}
+
+// WithGCVictim enables or disables the GC
+// victim generation.
+func WithGCVictim(enabled bool) Option {
+	return func(opts *Options) { opts.GCVictim = enabled }
+}
+
+// WithMemoryLimit enables shrinking against
+// the Go memory limit and, when `cgroup` is
+// not empty, the cgroup `memory.max` file.
+func WithMemoryLimit(enabled bool, cgroup string) Option {
+	return func(opts *Options) {
+		opts.MemoryLimit = enabled
+		opts.CgroupPath = cgroup
+	}
+}
+
+// WithWatermarks sets the shares of the memory
+// limit that start and stop shrinking.
+func WithWatermarks(high float64, low float64) Option {
+	return func(opts *Options) {
+		opts.HighWatermark = high
+		opts.LowWatermark = low
+	}
+}
+
+// WithOffHeap serves classes of `size` bytes
+// and more from anonymous mappings. Such
+// buffers must be released as handed out and
+// never used afterwards, see
+// `Options.OffHeapSize`.
+func WithOffHeap(size int, hugepages bool) Option {
+	return func(opts *Options) {
+		opts.OffHeapSize = size
+		opts.HugePages = hugepages
+	}
+}
+
+// WithSlabs carves classes up to `max` bytes
+// out of slabs of `size` bytes.
+func WithSlabs(size int, max int) Option {
+	return func(opts *Options) {
+		opts.SlabSize = size
+		opts.SlabMaxSize = max
+	}
+}
+
+// WithZero sets the zeroing policy.
+func WithZero(policy ZeroPolicy) Option {
+	return func(opts *Options) { opts.Zero = policy }
+}
+
+// WithDebug enables or disables poisoning.
+func WithDebug(enabled bool) Option {
+	return func(opts *Options) { opts.Debug = enabled }
+}
+
+// WithOwnership enables or disables ownership
+// tracking.
+func WithOwnership(enabled bool) Option {
+	return func(opts *Options) { opts.TrackOwnership = enabled }
+}
+
+// WithOnError sets the error callback.
+func WithOnError(fn func(error)) Option {
+	return func(opts *Options) { opts.OnError = fn }
+}
+
+// WithLeakTracking enables stack recording of
+// one in `rate` outstanding buffers, along
+// with ownership tracking.
+func WithLeakTracking(rate int) Option {
+	return func(opts *Options) {
+		opts.LeakTracking = true
+		opts.TrackOwnership = true
+		opts.LeakSampleRate = rate
+	}
+}
+
+// WithProfile registers the outstanding buffers
+// profile under `name`.
+func WithProfile(name string) Option {
+	return func(opts *Options) { opts.ProfileName = name }
+}
+
+// WithObserver sets the event observer.
+func WithObserver(obs Observer) Option {
+	return func(opts *Options) { opts.Observer = obs }
+}
+
+// WithTrace enables or disables trace regions.
+func WithTrace(enabled bool) Option {
+	return func(opts *Options) { opts.Trace = enabled }
+}
+
+// WithStats enables or disables statistics.
+func WithStats(enabled bool) Option {
+	return func(opts *Options) { opts.Stats = enabled }
+}
+
+// WithAuto enables or disables auto mode.
+// `percentile` and `max` are applied when
+// they are positive.
+func WithAuto(enabled bool, percentile float64, max int) Option {
+	return func(opts *Options) {
+		opts.Auto = enabled
+		if percentile > 0 {
+			opts.Percentile = percentile
+		}
+		if max > 0 {
+			opts.AutoMaxSize = max
+		}
+	}
+}
+
+func (o *Options) validate() error {
+	switch {
+	case !isPow2(o.MinSize) || o.MinSize < blocks[0]:
+		return &OptionError{"MinSize", o.MinSize, "must be a power of two >= 2"}
+	case !isPow2(o.MaxSize) || o.MaxSize > blocks[len(blocks)-1]:
+		return &OptionError{"MaxSize", o.MaxSize, fmt.Sprintf("must be a power of two <= %d", blocks[len(blocks)-1])}
+	case o.MinSize > o.MaxSize:
+		return &OptionError{"MaxSize", o.MaxSize, "must not be smaller than MinSize"}
+	case o.SegmentSize < 1 || o.SegmentSize > cSegMax:
+		return &OptionError{"SegmentSize", o.SegmentSize, fmt.Sprintf("must be in [1, %d]", cSegMax)}
+	case o.LocalCacheSize < 0 || o.LocalCacheSize > cLocalMax:
+		return &OptionError{"LocalCacheSize", o.LocalCacheSize, fmt.Sprintf("must be in [0, %d]", cLocalMax)}
+	case o.MaxRetained < 0:
+		return &OptionError{"MaxRetained", o.MaxRetained, "must not be negative"}
+	case o.MaxRetainedBytes < 0:
+		return &OptionError{"MaxRetainedBytes", o.MaxRetainedBytes, "must not be negative"}
+	case o.TrimInterval < 0:
+		return &OptionError{"TrimInterval", o.TrimInterval, "must not be negative"}
+	case o.IdleTimeout < 0:
+		return &OptionError{"IdleTimeout", o.IdleTimeout, "must not be negative"}
+	case o.HighWatermark <= 0 || o.HighWatermark > 1:
+		return &OptionError{"HighWatermark", o.HighWatermark, "must be in (0, 1]"}
+	case o.LowWatermark <= 0 || o.LowWatermark >= o.HighWatermark:
+		return &OptionError{"LowWatermark", o.LowWatermark, "must be in (0, HighWatermark)"}
+	case o.OffHeapSize < 0:
+		return &OptionError{"OffHeapSize", o.OffHeapSize, "must not be negative"}
+	case o.SlabSize < 0 || o.SlabMaxSize < 0:
+		return &OptionError{"SlabSize", o.SlabSize, "must not be negative"}
+	case o.SlabSize > 0 && (o.SlabMaxSize == 0 || o.SlabMaxSize*2 > o.SlabSize):
+		return &OptionError{"SlabMaxSize", o.SlabMaxSize, "must be positive and at most half of SlabSize"}
+	case o.Oversize != OversizeAlloc && o.Oversize != OversizeError:
+		return &OptionError{"Oversize", o.Oversize, "unknown oversize policy"}
+	case o.HugeRetained < 0 || (o.HugeRetained > 0 && o.Oversize != OversizeAlloc):
+		return &OptionError{"HugeRetained", o.HugeRetained, "must not be negative and requires OversizeAlloc"}
+	case o.Misfit != MisfitCopy && o.Misfit != MisfitRoundDown && o.Misfit != MisfitDrop:
+		return &OptionError{"Misfit", o.Misfit, "unknown misfit policy"}
+	case o.Zero != ZeroNone && o.Zero != ZeroOnRelease && o.Zero != ZeroOnGet:
+		return &OptionError{"Zero", o.Zero, "unknown zeroing policy"}
+	case o.LeakTracking && !o.TrackOwnership:
+		return &OptionError{"LeakTracking", o.LeakTracking, "requires TrackOwnership"}
+	case o.LeakSampleRate < 0:
+		return &OptionError{"LeakSampleRate", o.LeakSampleRate, "must not be negative"}
+	case o.Percentile <= 0 || o.Percentile > 1:
+		return &OptionError{"Percentile", o.Percentile, "must be in (0, 1]"}
+	case o.Auto && !o.Stats:
+		return &OptionError{"Auto", o.Auto, "requires Stats"}
+	case o.Auto && (o.AutoMaxSize < o.MinSize || o.AutoMaxSize > o.MaxSize):
+		return &OptionError{"AutoMaxSize", o.AutoMaxSize, "must be in [MinSize, MaxSize]"}
+	}
+	if l, ok := o.Layout.(geometricLayout); ok && (l.factor <= 1 || l.factor > 2) {
+		return &OptionError{"Layout", l.factor, "geometric factor must be in (1, 2]"}
+	}
+	for _, size := range o.Classes {
+		if size < o.MinSize || size > o.MaxSize {
+			return &OptionError{"Classes", size, "must be in [MinSize, MaxSize]"}
+		}
+	}
+	for size, count := range o.ClassRetained {
+		if count < 0 {
+			return &OptionError{"ClassRetained", count, "must not be negative"}
+		}
+		if size < o.MinSize || size > o.MaxSize {
+			return &OptionError{"ClassRetained", size, "must be in [MinSize, MaxSize]"}
+		}
+	}
+	return nil
+}
+
+// limits returns the per class retention caps
+// of `ct`, zero meaning no cap.
+func (o *Options) limits(ct *classTable) ([]int64, error) {
+	var caps []int64 = make([]int64, len(ct.sizes))
+	for i := range caps {
+		caps[i] = int64(o.MaxRetained)
+	}
+	for size, count := range o.ClassRetained {
+		index := ct.index(size)
+		if ct.size(index) != size {
+			return nil, &OptionError{"ClassRetained", size, "is not a class size"}
+		}
+		caps[index] = int64(count)
+	}
+	return caps, nil
+}
+
+// tracked reports whether retained buffers
+// must be accounted.
+func (o *Options) tracked() bool {
+	return o.Stats || o.TrimInterval > 0 || o.watched() || o.MaxRetained > 0 || o.MaxRetainedBytes > 0 || len(o.ClassRetained) > 0
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("lfpool: invalid option %s=%v: %s.", e.Field, e.Value, e.Reason)
+}
+
+func isPow2(num int) bool {
+	return num > 0 && (num&(num-1)) == 0
+}
+
+// idle returns the idle window of trimming
+// passes, in nanoseconds.
+func (o *Options) idle() int64 {
+	if o.IdleTimeout > 0 {
+		return int64(o.IdleTimeout)
+	}
+	return int64(o.TrimInterval)
+}
+
+// watched reports whether the janitor checks
+// memory limits.
+func (o *Options) watched() bool {
+	return o.MemoryLimit || o.CgroupPath != ""
+}
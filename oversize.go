@@ -0,0 +1,206 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// - MARK: oversize section.
+
+// OversizePolicy tells how requests above
+// `MaxSize` are served.
+type OversizePolicy int
+
+const (
+	// OversizeAlloc serves them with exact
+	// size buffers outside of the classes,
+	// optionally cached, see `HugeRetained`.
+	OversizeAlloc OversizePolicy = iota
+	// OversizeError rejects them, `Get` panics
+	// and `TryGet` returns `ErrTooLarge`.
+	OversizeError
+)
+
+const (
+	cHugeSlack = 2 // largest capacity/request ratio served from the cache
+)
+
+// hugeCache keeps a few oversized buffers.
+// They are rare and large, a mutex and a
+// best fit scan are cheap next to their
+// allocation.
+type hugeCache struct {
+	mu     sync.Mutex
+	chunks [][]byte
+	hits   uint64 // since the previous trim
+}
+
+// oversize returns a buffer of at least
+// `size` bytes for a request above the
+// largest class.
+func (lfp *LFPool) oversize(size int, zero bool) []byte {
+	if chunk := lfp.huge.take(size); chunk != nil {
+		if zero {
+			lfp.zero(chunk)
+		}
+		if lfp.stats != nil {
+			atomic.AddUint64(&lfp.stats.huge.hits, 1)
+		}
+		if lfp.opts.Observer != nil {
+			lfp.opts.Observer.Hit(cClassHuge, cap(chunk))
+		}
+		return chunk
+	}
+	var (
+		r     = lfp.region("lfpool.alloc")
+		chunk []byte
+	)
+	if lfp.align > 0 {
+		chunk = alignedChunk(size, lfp.align)
+	} else {
+		chunk = make([]byte, size)
+	}
+	endRegion(r)
+	if lfp.stats != nil {
+		atomic.AddUint64(&lfp.stats.huge.allocs, 1)
+	}
+	if lfp.opts.Observer != nil {
+		lfp.opts.Observer.Miss(cClassHuge, size)
+	}
+	return chunk
+}
+
+// releaseHuge retains the oversized `chunk`
+// when the cache has room, otherwise it is
+// left to the GC.
+func (lfp *LFPool) releaseHuge(chunk []byte) {
+	if lfp.stats != nil {
+		atomic.AddUint64(&lfp.stats.huge.rels, 1)
+	}
+	if lfp.opts.Observer != nil {
+		lfp.opts.Observer.Release(cClassHuge, cap(chunk))
+	}
+	if lfp.opts.HugeRetained > 0 {
+		if lfp.opts.Zero == ZeroOnRelease {
+			lfp.zero(chunk)
+		}
+		if n := lfp.huge.put(chunk, lfp.opts.HugeRetained); n > 0 {
+			if lfp.stats != nil {
+				peak(&lfp.stats.huge.max, int64(n))
+			}
+			if atomic.LoadUint32(&lfp.closed) != 0 {
+				lfp.flushHuge(true)
+			}
+			return
+		}
+	}
+	lfp.dropHuge(chunk)
+}
+
+// flushHuge drops the cached oversized buffers
+// and returns the freed bytes. Unless `force`
+// is set, a cache that served a request since
+// the previous call is kept.
+func (lfp *LFPool) flushHuge(force bool) int64 {
+	var freed int64
+	if atomic.SwapUint64(&lfp.huge.hits, 0) != 0 && !force {
+		return 0
+	}
+	for _, chunk := range lfp.huge.flush() {
+		freed += int64(cap(chunk))
+		lfp.dropHuge(chunk)
+	}
+	return freed
+}
+
+// dropHuge accounts the oversized `chunk`
+// left to the GC.
+func (lfp *LFPool) dropHuge(chunk []byte) {
+	if lfp.stats != nil {
+		atomic.AddUint64(&lfp.stats.huge.deallocs, 1)
+	}
+	if lfp.opts.Observer != nil {
+		lfp.opts.Observer.Drop(cClassHuge, cap(chunk))
+	}
+	lfp.forget(chunk)
+}
+
+// take removes the smallest cached buffer
+// that can serve `size` bytes without
+// wasting more than `cHugeSlack` times it.
+func (hc *hugeCache) take(size int) []byte {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	var best int = -1
+	for i, chunk := range hc.chunks {
+		if c := cap(chunk); c >= size && c/cHugeSlack <= size && (best == -1 || c < cap(hc.chunks[best])) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return nil
+	}
+	chunk := hc.chunks[best]
+	last := len(hc.chunks) - 1
+	hc.chunks[best], hc.chunks[last] = hc.chunks[last], nil
+	hc.chunks = hc.chunks[:last]
+	atomic.AddUint64(&hc.hits, 1)
+	return chunk
+}
+
+// put caches `chunk` and returns the number of
+// cached buffers, or zero when the cache holds
+// `max` buffers already.
+func (hc *hugeCache) put(chunk []byte, max int) int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if len(hc.chunks) >= max {
+		return 0
+	}
+	hc.chunks = append(hc.chunks, chunk[:cap(chunk)])
+	return len(hc.chunks)
+}
+
+// flush empties the cache and returns the
+// buffers it held.
+func (hc *hugeCache) flush() [][]byte {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	ret := hc.chunks
+	hc.chunks = nil
+	return ret
+}
+
+// size returns the number and total capacity
+// of the cached buffers.
+func (hc *hugeCache) size() (int64, int64) {
+	var bytes int64
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	for _, chunk := range hc.chunks {
+		bytes += int64(cap(chunk))
+	}
+	return int64(len(hc.chunks)), bytes
+}
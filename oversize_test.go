@@ -0,0 +1,86 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOversize(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithMaxSize(1<<20), WithOversize(OversizeAlloc, 2), WithDebug(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.Get(3 << 20)
+	if len(b) != 3<<20 || cap(b) != 3<<20 {
+		t.Fatal("invalid oversized buffer", len(b), cap(b))
+	}
+	if c, err := bp.TryGet(10, 5<<20); err != nil || len(c) != 10 || cap(c) != 5<<20 {
+		t.Fatal("invalid oversized buffer", err)
+	}
+	if n := bp.ClassOf(3 << 20); n != 0 {
+		t.Fatal("invalid class", n)
+	}
+	if err := bp.TryRelease(b); err != nil {
+		t.Fatal(err)
+	}
+	if c := bp.Get(2 << 20); &c[0] != &b[0] {
+		t.Fatal("expected reuse")
+	} else {
+		bp.Release(c)
+	}
+	if c := bp.Get(1<<20 + 1); &c[0] == &b[0] {
+		t.Fatal("reused a buffer over twice the request")
+	}
+	if n := bp.Shrink(1); n != 3<<20 {
+		t.Fatal("invalid freed bytes", n)
+	}
+	if c := bp.Get(2 << 20); cap(c) != 2<<20 {
+		t.Fatal("invalid oversized buffer", cap(c))
+	}
+
+	bp, _ = NewLFPoolWithOptions(WithMaxSize(1<<20), WithOversize(OversizeError, 0))
+	if _, err := bp.TryGet(0, 2<<20); !errors.Is(err, ErrTooLarge) {
+		t.Fatal("expected ErrTooLarge", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic")
+			}
+		}()
+		bp.Get(2 << 20)
+	}()
+
+	top := blocks[len(blocks)-1]
+	bp, err = NewLFPoolWithOptions(WithMaxSize(top))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bp.ClassOf(top - 1); n != top {
+		t.Fatal("invalid class", n)
+	}
+}
@@ -0,0 +1,175 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+	"weak"
+)
+
+// - MARK: ownership section.
+
+const (
+	ownOut uint32 = iota + 1 // handed out
+	ownIn                    // retained by the pool
+)
+
+// ReleaseErrorKind tells why a release was
+// rejected.
+type ReleaseErrorKind int
+
+const (
+	// DoubleRelease is a release of a buffer
+	// that is already back in the pool.
+	DoubleRelease ReleaseErrorKind = iota + 1
+	// ForeignRelease is a release of a buffer
+	// that was not handed out by the pool.
+	ForeignRelease
+)
+
+// ReleaseError is reported when ownership
+// tracking rejects a release.
+type ReleaseError struct {
+	Kind ReleaseErrorKind
+	Size int // capacity of the released slice
+}
+
+// owner is the ownership record of a buffer
+// handed out by the pool. `alloc` is the
+// first record of the buffer, kept across
+// replacements.
+type owner struct {
+	state uint32
+	size  int
+	stack []uintptr // nil unless sampled
+	alloc *owner
+}
+
+// ownerRef is the argument of the cleanup
+// deleting the records of a buffer dropped
+// by its caller.
+type ownerRef struct {
+	pool  weak.Pointer[LFPool]
+	base  uintptr
+	alloc *owner
+}
+
+// own marks `chunk` as handed out. Records are
+// replaced instead of reused when stacks are
+// tracked, so that reports never race.
+func (lfp *LFPool) own(chunk []byte) {
+	var base uintptr = uintptr(unsafe.Pointer(&chunk[:1][0]))
+	// NOTE
+	// . only a retained record belongs to
+	//   `chunk`, one still handed out is left
+	//   by a collected buffer whose cleanup has
+	//   yet to run.
+	if v, ok := lfp.owners.Load(base); ok && atomic.LoadUint32(&v.(*owner).state) == ownIn {
+		o := v.(*owner)
+		if lfp.opts.LeakTracking {
+			lfp.owners.Store(base, &owner{ownOut, cap(chunk), lfp.sample(), o.alloc})
+			return
+		}
+		atomic.StoreUint32(&o.state, ownOut)
+		return
+	}
+	o := &owner{state: ownOut, size: cap(chunk)}
+	o.alloc = o
+	if lfp.opts.LeakTracking {
+		o.stack = lfp.sample()
+	}
+	lfp.owners.Store(base, o)
+	if _, ok := lfp.mmaps.Load(base); !ok {
+		runtime.AddCleanup(&chunk[:1][0], forgetOwner, ownerRef{weak.Make(lfp), base, o.alloc})
+	}
+}
+
+// forgetOwner deletes the records of a buffer
+// collected without being released, unless
+// the address went to a new buffer meanwhile.
+func forgetOwner(r ownerRef) {
+	lfp := r.pool.Value()
+	if lfp == nil {
+		return
+	}
+	if v, ok := lfp.owners.Load(r.base); ok && v.(*owner).alloc == r.alloc {
+		lfp.owners.CompareAndDelete(r.base, v)
+	}
+}
+
+// disown marks `chunk` as retained, it fails
+// when `chunk` is already retained or was
+// never handed out.
+func (lfp *LFPool) disown(chunk []byte) error {
+	if cap(chunk) == 0 {
+		return &ReleaseError{ForeignRelease, 0}
+	}
+	v, ok := lfp.owners.Load(uintptr(unsafe.Pointer(&chunk[:1][0])))
+	if !ok {
+		return &ReleaseError{ForeignRelease, cap(chunk)}
+	}
+	if !atomic.CompareAndSwapUint32(&v.(*owner).state, ownOut, ownIn) {
+		return &ReleaseError{DoubleRelease, cap(chunk)}
+	}
+	return nil
+}
+
+// report hands `err` to `OnError`, or panics
+// with it in debug mode.
+func (lfp *LFPool) report(err error) {
+	if lfp.opts.Debug {
+		panic(err)
+	}
+	if lfp.opts.OnError != nil {
+		lfp.opts.OnError(err)
+	}
+}
+
+func (k ReleaseErrorKind) String() string {
+	switch k {
+	case DoubleRelease:
+		return "double release"
+	case ForeignRelease:
+		return "foreign release"
+	}
+	return fmt.Sprintf("ReleaseErrorKind(%d)", int(k))
+}
+
+// Is makes `ReleaseError` match `ErrForeign`
+// or `ErrDoubleRelease` with `errors.Is`.
+func (e *ReleaseError) Is(target error) bool {
+	switch e.Kind {
+	case DoubleRelease:
+		return target == ErrDoubleRelease
+	case ForeignRelease:
+		return target == ErrForeign
+	}
+	return false
+}
+
+func (e *ReleaseError) Error() string {
+	return fmt.Sprintf("lfpool: %s of a %d bytes buffer.", e.Kind, e.Size)
+}
@@ -0,0 +1,116 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"runtime"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestOwnership(t *testing.T) {
+	var errs []error
+	bp, err := NewLFPoolWithOptions(
+		WithOwnership(true),
+		WithDebug(false),
+		WithOnError(func(err error) { errs = append(errs, err) }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.Get(1024)
+	bp.Release(b)
+	bp.Release(b)
+	bp.Release(make([]byte, 1024))
+	if len(errs) != 2 {
+		t.Fatal("invalid error count", len(errs))
+	}
+	if e, ok := errs[0].(*ReleaseError); !ok || e.Kind != DoubleRelease || e.Size != 1024 {
+		t.Fatal("invalid error", errs[0])
+	}
+	if e, ok := errs[1].(*ReleaseError); !ok || e.Kind != ForeignRelease {
+		t.Fatal("invalid error", errs[1])
+	}
+	// the double release must not hand out the
+	// buffer twice.
+	c, d := bp.Get(1024), bp.Get(1024)
+	if &c[0] == &d[0] {
+		t.Fatal("buffer handed out twice")
+	}
+	bp.Release(c)
+	bp.Release(d)
+	if len(errs) != 2 {
+		t.Fatal("unexpected error", errs[2:])
+	}
+}
+
+func TestOwnershipDebug(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithOwnership(true), WithDebug(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.Get(64)
+	bp.Release(b)
+	defer func() {
+		if e, ok := recover().(*ReleaseError); !ok || e.Kind != DoubleRelease {
+			t.Fatal("expected double release panic", e)
+		}
+	}()
+	bp.Release(b)
+}
+
+func TestOwnershipForget(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithOwnership(true), WithMisfit(MisfitDrop), WithMaxSize(64<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := func() (n int) {
+		bp.owners.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	bp.Release(bp.Get(1024)[:0:1000])
+	bp.Release(bp.Get(128 << 10))
+	if n := records(); n != 0 {
+		t.Fatal("records kept for dropped buffers", n)
+	}
+	b := bp.Get(4096)
+	base := uintptr(unsafe.Pointer(&b[0]))
+	b = nil
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := bp.owners.Load(base); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("record kept for a collected buffer")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
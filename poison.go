@@ -0,0 +1,106 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// - MARK: poison section.
+
+const (
+	cPoisonDepth = 32
+)
+
+// poisonPage is the pattern written over
+// released buffers in debug mode.
+var poisonPage []byte = bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef}, 1024)
+
+// poison overwrites the whole capacity of
+// `chunk` and records the release stack.
+func (lfp *LFPool) poison(chunk []byte) {
+	var (
+		pcs [cPoisonDepth]uintptr
+		n   int = runtime.Callers(3, pcs[:])
+	)
+	chunk = chunk[:cap(chunk)]
+	for off := 0; off < len(chunk); off += len(poisonPage) {
+		copy(chunk[off:], poisonPage)
+	}
+	lfp.poisons.Store(uintptr(unsafe.Pointer(&chunk[0])), append([]uintptr(nil), pcs[:n]...))
+}
+
+// verify checks that `chunk` of class `index`
+// still holds the poison pattern and panics
+// with its release stack otherwise.
+func (lfp *LFPool) verify(index int, chunk []byte) {
+	var (
+		base  uintptr = uintptr(unsafe.Pointer(&chunk[:1][0]))
+		stack interface{}
+		ok    bool
+	)
+	if stack, ok = lfp.poisons.LoadAndDelete(base); !ok {
+		return
+	}
+	chunk = chunk[:cap(chunk)]
+	for off := 0; off < len(chunk); off += len(poisonPage) {
+		end := off + len(poisonPage)
+		if end > len(chunk) {
+			end = len(chunk)
+		}
+		if bytes.Equal(chunk[off:end], poisonPage[:end-off]) {
+			continue
+		}
+		for i := off; i < end; i++ {
+			if chunk[i] != poisonPage[i-off] {
+				off = i
+				break
+			}
+		}
+		panic(fmt.Sprintf(
+			"core(pool): buffer of class %d (%d bytes) modified at offset %d after release, released at:\n%s",
+			index-lfp.classes.lo, cap(chunk), off, formatStack(stack.([]uintptr)),
+		))
+	}
+}
+
+// formatStack renders program counters the way
+// panics do.
+func formatStack(pcs []uintptr) string {
+	var (
+		sb     strings.Builder
+		frames *runtime.Frames = runtime.CallersFrames(pcs)
+	)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
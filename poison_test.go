@@ -0,0 +1,63 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPoison(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithDebug(true), WithLocalCacheSize(0), WithZero(ZeroOnRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.Get(100)
+	bp.Release(b)
+	c := bp.Get(100)
+	if &c[0] != &b[0] {
+		t.Fatal("expected reuse")
+	}
+	for i := range c {
+		if c[i] != 0 {
+			t.Fatal("buffer not cleared", i)
+		}
+	}
+	bp.Release(c)
+	// use after release.
+	c[70] = 1
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected panic")
+		}
+		msg := fmt.Sprint(r)
+		if !strings.Contains(msg, "offset 70") || !strings.Contains(msg, "TestPoison") {
+			t.Fatal("invalid panic message", msg)
+		}
+	}()
+	bp.Get(100)
+}
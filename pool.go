@@ -0,0 +1,262 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// - MARK: Pool section.
+
+// Pool is a pool of `*T` values kept on the
+// same lock-free segments as `LFPool`, with
+// the same retention cap, trimming and
+// statistics. Only `SegmentSize`, `Stats`,
+// `MaxRetained`, `TrimInterval`, `IdleTimeout`
+// and `OnTrim` apply to it, `OnTrim` and
+// `Trim` count values instead of bytes.
+type Pool[T any] struct {
+	slot   pslot
+	count  pcount
+	stats  *stat
+	opts   Options
+	newFn  func() *T
+	reset  func(*T)
+	closed uint32
+	jan    janitor
+}
+
+// NewPool initializes and allocates a new
+// `Pool` whose values are created by `newFn`
+// and cleared by `reset`, when not nil,
+// before they are retained. It returns an
+// `*OptionError` for invalid options.
+func NewPool[T any](newFn func() *T, reset func(*T), opts ...Option) (*Pool[T], error) {
+	var o Options = DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if newFn == nil {
+		return nil, &OptionError{"New", nil, "must not be nil"}
+	}
+	var p *Pool[T] = &Pool[T]{
+		opts:  o,
+		newFn: newFn,
+		reset: reset,
+	}
+	p.slot.seg = uint32(o.SegmentSize)
+	p.slot.entry = unsafe.Pointer(newlfsliceSize(p.slot.seg))
+	if o.Stats {
+		p.stats = &stat{}
+	}
+	return p, nil
+}
+
+// Get returns a pooled value, or a new one
+// when the pool is empty or closed.
+func (p *Pool[T]) Get() *T {
+	if atomic.LoadUint32(&p.closed) != 0 {
+		return p.newFn()
+	}
+	if v := (*lfslice)(p.slot.ldEntry()).pop(); v != nil {
+		p.unreserve(1)
+		if p.stats != nil {
+			atomic.AddUint64(&p.stats.hits, 1)
+		}
+		return (*T)(v)
+	}
+	if p.stats != nil {
+		atomic.AddUint64(&p.stats.allocs, 1)
+	}
+	return p.newFn()
+}
+
+// Put resets `x` and retains it, unless the
+// pool is closed or full.
+func (p *Pool[T]) Put(x *T) {
+	if x == nil || atomic.LoadUint32(&p.closed) != 0 {
+		return
+	}
+	if p.reset != nil {
+		p.reset(x)
+	}
+	if !p.reserve() {
+		if p.stats != nil {
+			atomic.AddUint64(&p.stats.deallocs, 1)
+		}
+		return
+	}
+	p.slot.insert(unsafe.Pointer(x))
+	// NOTE
+	// . a racing `Close` either drains `x` or
+	//   is seen here, see `LFPool.put`.
+	if atomic.LoadUint32(&p.closed) != 0 {
+		p.cleanUp(p.slot.detach())
+	}
+	if p.stats != nil {
+		atomic.AddUint64(&p.stats.rels, 1)
+	}
+}
+
+// Len returns the number of retained values.
+func (p *Pool[T]) Len() int {
+	return int(atomic.LoadInt64(&p.count.count))
+}
+
+// Stats returns the statistics of the pool,
+// `Size` and `RetainedBytes` count the shallow
+// size of `T`.
+func (p *Pool[T]) Stats() ClassStats {
+	var ret ClassStats = ClassStats{
+		Size:     int(unsafe.Sizeof(*new(T))),
+		Retained: atomic.LoadInt64(&p.count.count),
+	}
+	ret.RetainedBytes = ret.Retained * int64(ret.Size)
+	if p.stats != nil {
+		p.stats.load(&ret)
+	}
+	return ret
+}
+
+// ResetStats zeroes the counters of the pool,
+// see `LFPool.ResetStats`.
+func (p *Pool[T]) ResetStats() {
+	if p.stats != nil {
+		p.stats.reset(atomic.LoadInt64(&p.count.count))
+	}
+}
+
+// Close drops every retained value and stops
+// the janitor. Afterwards `Get` falls back to
+// `newFn` and `Put` is a no-op. It returns
+// `ErrClosed` when already closed.
+func (p *Pool[T]) Close() error {
+	if !atomic.CompareAndSwapUint32(&p.closed, 0, 1) {
+		return ErrClosed
+	}
+	p.Stop()
+	p.cleanUp(p.slot.detach())
+	return nil
+}
+
+// Start launches the janitor, which trims
+// idle values every `TrimInterval`. It
+// returns `LPNotSupported` when trimming
+// is disabled and `ErrClosed` after `Close`.
+func (p *Pool[T]) Start() error {
+	if p.opts.TrimInterval <= 0 {
+		return LPNotSupported
+	}
+	if atomic.LoadUint32(&p.closed) != 0 {
+		return ErrClosed
+	}
+	p.jan.start(p.opts.TrimInterval, p.tick)
+	if atomic.LoadUint32(&p.closed) != 0 {
+		p.jan.halt()
+		return ErrClosed
+	}
+	return nil
+}
+
+// Stop stops the janitor and waits for it
+// to exit.
+func (p *Pool[T]) Stop() {
+	p.jan.halt()
+}
+
+// Trim drops every value that was not reused
+// since the previous pass and returns their
+// number. It is a no-op when trimming is
+// disabled.
+func (p *Pool[T]) Trim() int64 {
+	if p.opts.TrimInterval <= 0 {
+		return 0
+	}
+	return p.trim(time.Now().UnixNano(), true)
+}
+
+// tick is one pass of the janitor.
+func (p *Pool[T]) tick(now int64) {
+	freed := p.trim(now, false)
+	if p.opts.OnTrim != nil {
+		p.opts.OnTrim(freed)
+	}
+}
+
+// trim drops the low watermark of the pool
+// once its idle window elapsed, or at once
+// when `force` is set, see `trimClass`.
+func (p *Pool[T]) trim(now int64, force bool) int64 {
+	var n int64
+	if !force && !p.count.due(now, p.opts.idle()) {
+		return 0
+	}
+	if low, all := p.count.watermark(); all {
+		n = p.cleanUp(p.slot.detach())
+	} else if low > 0 {
+		if n = (*lfslice)(p.slot.ldEntry()).drain(low, nil); n > 0 {
+			p.unreserve(n)
+			p.dropped(n)
+		}
+	}
+	p.count.rearm(now)
+	return n
+}
+
+// cleanUp drops every value of the detached
+// chain `head` and returns their number.
+func (p *Pool[T]) cleanUp(head *lfslice) int64 {
+	var n int64 = head.kill(nil)
+	if n > 0 {
+		p.unreserve(n)
+		p.dropped(n)
+	}
+	return n
+}
+
+func (p *Pool[T]) dropped(n int64) {
+	if p.stats != nil {
+		atomic.AddUint64(&p.stats.deallocs, uint64(n))
+	}
+}
+
+// reserve accounts one more retained value,
+// it fails when the pool is full.
+func (p *Pool[T]) reserve() bool {
+	n, ok := p.count.enter(int64(p.opts.MaxRetained))
+	if ok && p.stats != nil {
+		peak(&p.stats.max, n)
+	}
+	return ok
+}
+
+// unreserve accounts `n` values leaving the
+// pool, see `LFPool.unreserve`.
+func (p *Pool[T]) unreserve(n int64) {
+	p.count.leave(n, p.opts.TrimInterval > 0)
+}
@@ -0,0 +1,173 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type poolItem struct {
+	buf []byte
+	n   int
+}
+
+func TestPool(t *testing.T) {
+	var news int64
+	p, err := NewPool(func() *poolItem {
+		atomic.AddInt64(&news, 1)
+		return &poolItem{buf: make([]byte, 0, 64)}
+	}, func(x *poolItem) {
+		x.buf, x.n = x.buf[:0], 0
+	}, WithStats(true), WithMaxRetained(4), WithSegmentSize(2), WithTrim(time.Hour, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := p.Get()
+	x.buf, x.n = append(x.buf, "dirty"...), 7
+	p.Put(x)
+	if y := p.Get(); y != x || len(y.buf) != 0 || y.n != 0 {
+		t.Fatal("expected a reset reused value")
+	}
+	items := make([]*poolItem, 6)
+	for i := range items {
+		items[i] = p.Get()
+	}
+	for _, item := range items {
+		p.Put(item)
+	}
+	if n := p.Len(); n != 4 {
+		t.Fatal("invalid retained count", n)
+	}
+	if n := atomic.LoadUint64(&p.stats.deallocs); n != 2 {
+		t.Fatal("invalid deallocs", n)
+	}
+	if n := p.Trim(); n != 0 {
+		t.Fatal("trimmed a fresh pool", n)
+	}
+	p.Get()
+	if n := p.Trim(); n != 3 {
+		t.Fatal("invalid trimmed count", n)
+	}
+	if n := p.Len(); n != 0 {
+		t.Fatal("invalid retained count", n)
+	}
+	p.Put(&poolItem{})
+	p.Close()
+	if n := p.Len(); n != 0 {
+		t.Fatal("values retained after Close", n)
+	}
+	if _, err := NewPool[poolItem](nil, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestPoolParallel(t *testing.T) {
+	p, _ := NewPool(func() *poolItem { return &poolItem{} }, nil)
+	var (
+		wg    sync.WaitGroup
+		owner sync.Map
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				x := p.Get()
+				if _, loaded := owner.LoadOrStore(x, g); loaded {
+					t.Error("value handed out twice")
+					return
+				}
+				owner.Delete(x)
+				p.Put(x)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestPoolDetach(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	p, err := NewPool(func() *poolItem { return &poolItem{} }, nil, WithTrim(time.Hour, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg   sync.WaitGroup
+		stop = make(chan struct{})
+	)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					p.Put(p.Get())
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50000; i++ {
+		p.Trim()
+	}
+	close(stop)
+	wg.Wait()
+	p.Close()
+	if n := p.Len(); n != 0 {
+		t.Fatal("leaked reservations", n)
+	}
+	if err := p.Start(); err != ErrClosed {
+		t.Fatal("janitor started after close", err)
+	}
+}
+
+func TestPoolCloseRace(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for i := 0; i < 200; i++ {
+		p, _ := NewPool(func() *poolItem { return &poolItem{} }, nil)
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 256; j++ {
+					p.Put(&poolItem{})
+				}
+			}()
+		}
+		for p.Len() == 0 {
+			runtime.Gosched()
+		}
+		p.Close()
+		wg.Wait()
+		if n := p.Len(); n != 0 {
+			t.Fatal("values retained after close", n)
+		}
+	}
+}
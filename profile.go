@@ -0,0 +1,72 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"runtime/pprof"
+	"sync"
+	"unsafe"
+)
+
+// - MARK: profile section.
+
+const (
+	// frames between `profileAdd` and the
+	// `Get`, `TryGet` or `GetAligned` call,
+	// which is the leaf of recorded stacks.
+	cProfileSkip = 3
+)
+
+// profileMu serializes profile registration,
+// `pprof.NewProfile` panics on a name that is
+// already taken.
+var profileMu sync.Mutex
+
+// newProfile registers the pprof profile
+// `name`, it fails when the name is taken.
+func newProfile(name string) (*pprof.Profile, error) {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	if pprof.Lookup(name) != nil {
+		return nil, &OptionError{"ProfileName", name, "is already registered"}
+	}
+	return pprof.NewProfile(name), nil
+}
+
+// profileAdd records `chunk` in the pprof
+// profile. Entries are keyed by address, a
+// stale entry left by a buffer that was never
+// released is replaced.
+func (lfp *LFPool) profileAdd(chunk []byte) {
+	var base uintptr = uintptr(unsafe.Pointer(&chunk[:1][0]))
+	lfp.profile.Remove(base)
+	lfp.profile.Add(base, cProfileSkip)
+}
+
+// profileRemove drops `chunk` from the pprof
+// profile.
+func (lfp *LFPool) profileRemove(chunk []byte) {
+	if cap(chunk) > 0 {
+		lfp.profile.Remove(uintptr(unsafe.Pointer(&chunk[:1][0])))
+	}
+}
@@ -0,0 +1,86 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func profileHolder(bp *LFPool) []byte { return bp.Get(512) }
+
+func TestProfile(t *testing.T) {
+	const name = "github.com/mitghi/lfpool.test"
+	bp, err := NewLFPoolWithOptions(WithProfile(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	held := [][]byte{profileHolder(bp), profileHolder(bp), bp.GetAligned(100, 64)}
+	p := pprof.Lookup(name)
+	if p == nil || p.Count() != 3 {
+		t.Fatal("invalid profile")
+	}
+	var out bytes.Buffer
+	if err := p.WriteTo(&out, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "profileHolder") {
+		t.Fatal("caller missing from profile", out.String())
+	}
+	bp.Release(held[0])
+	bp.Release(held[1])
+	bp.ReleaseAligned(held[2], 64)
+	if n := p.Count(); n != 0 {
+		t.Fatal("invalid profile count", n)
+	}
+	if _, err := NewLFPoolWithOptions(WithProfile(name)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestProfileRace(t *testing.T) {
+	const name = "github.com/mitghi/lfpool.race"
+	var (
+		wg sync.WaitGroup
+		ok int32
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := NewLFPoolWithOptions(WithProfile(name)); err == nil {
+				atomic.AddInt32(&ok, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != 1 {
+		t.Fatal("invalid registration count", ok)
+	}
+}
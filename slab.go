@@ -0,0 +1,76 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// - MARK: slab section.
+
+// slab is a large allocation cut into buffers
+// of a single class. `off` is bumped past
+// every buffer handed out.
+type slab struct {
+	data []byte
+	off  int64
+}
+
+// carve cuts a new buffer of class `index` out
+// of its current slab, replacing the slab
+// when it is exhausted. Buffers are capped
+// so that appends never overlap.
+func (lfp *LFPool) carve(index int) []byte {
+	var (
+		size int64           = int64(lfp.classes.size(index))
+		ptr  *unsafe.Pointer = &lfp.slabs[index]
+	)
+	for {
+		curr := atomic.LoadPointer(ptr)
+		if s := (*slab)(curr); s != nil {
+			off := atomic.AddInt64(&s.off, size)
+			if off <= int64(len(s.data)) {
+				return s.data[off-size : off : off]
+			}
+		}
+		s := &slab{
+			data: make([]byte, int64(lfp.opts.SlabSize)-int64(lfp.opts.SlabSize)%size),
+			off:  size,
+		}
+		if atomic.CompareAndSwapPointer(ptr, curr, unsafe.Pointer(s)) {
+			atomic.AddInt64(&lfp.nslabs, 1)
+			if lfp.stats != nil {
+				atomic.AddUint64(&lfp.stats.blocks[index].slabs, 1)
+			}
+			return s.data[0:size:size]
+		}
+	}
+}
+
+// Slabs returns the number of slabs allocated
+// so far and their total size.
+func (lfp *LFPool) Slabs() (count int64, size int64) {
+	count = atomic.LoadInt64(&lfp.nslabs)
+	return count, count * int64(lfp.opts.SlabSize)
+}
@@ -0,0 +1,107 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestSlabs(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithSlabs(64<<10, 256), WithStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen map[*byte]bool = make(map[*byte]bool)
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1024; j++ {
+				b := bp.Get(64)
+				if cap(b) != 64 {
+					t.Error("invalid capacity", cap(b))
+					return
+				}
+				base := &b[0]
+				mu.Lock()
+				if seen[base] {
+					t.Error("overlapping buffer")
+				}
+				seen[base] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	count, _ := bp.Slabs()
+	if count != 8 {
+		t.Fatal("invalid slab count", count)
+	}
+	if n := bp.stats.blocks[bp.classes.index(64)].slabs; n != 8 {
+		t.Fatal("invalid class slab count", n)
+	}
+	if b := bp.Get(512); cap(b) != 512 {
+		t.Fatal("invalid capacity", cap(b))
+	}
+	if count, _ := bp.Slabs(); count != 8 {
+		t.Fatal("large class carved from slab", count)
+	}
+	a, c := bp.Get(128), bp.Get(128)
+	if uintptr(unsafe.Pointer(&c[0])) != uintptr(unsafe.Pointer(&a[0]))+128 {
+		t.Fatal("buffers not carved next to each other")
+	}
+	base := &a[0]
+	if a = append(a, 0xff); &a[0] == base {
+		t.Fatal("append grew a buffer in place")
+	}
+	for i, x := range c {
+		if x != 0 {
+			t.Fatal("neighbouring buffer overwritten at", i)
+		}
+	}
+	if _, err := NewLFPoolWithOptions(WithSlabs(256, 256)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func BenchmarkColdSmall(b *testing.B) {
+	for _, slabs := range []int{0, 64 << 10} {
+		b.Run(map[bool]string{true: "slab", false: "heap"}[slabs > 0], func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bp, _ := NewLFPoolWithOptions(WithSlabs(slabs, 256))
+				for j := 0; j < 4096; j++ {
+					bp.Get(64)
+				}
+			}
+		})
+	}
+}
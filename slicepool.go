@@ -0,0 +1,288 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// - MARK: SlicePool section.
+
+// SlicePool is a pool of `[]E` scratch slices
+// on the size class machinery of `LFPool`,
+// where sizes count elements instead of bytes.
+// Only `MinSize`, `MaxSize`, `Layout`,
+// `Classes`, `SegmentSize`, `Stats`,
+// `MaxRetained`, `Oversize` and `Zero` apply
+// to it. Slices whose capacity is not a class
+// size are retained in the largest class they
+// fit. Element types holding pointers want
+// `ZeroOnRelease`, retained slices keep their
+// referents reachable otherwise.
+type SlicePool[E any] struct {
+	slots    []pslot
+	counts   []pcount
+	classes  *classTable
+	stats    *Stats
+	retained int64 // retained bytes
+	opts     Options
+}
+
+// Vec is a growable slice backed by a
+// `SlicePool`, the `[]E` analogue of `Buffer`.
+// Growing it takes the next class from the
+// pool and releases the previous one.
+type Vec[E any] struct {
+	Data []E
+	mp   *SlicePool[E]
+}
+
+// NewSlicePool initializes and allocates a
+// new `SlicePool`. It returns an `*OptionError`
+// for invalid options.
+func NewSlicePool[E any](opts ...Option) (*SlicePool[E], error) {
+	var o Options = DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if o.Layout == nil {
+		o.Layout = PowerOfTwo()
+	}
+	ct, err := newClassTable(o.Layout.Classes(o.MinSize, o.MaxSize), o.Classes, o.MinSize, o.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	var sp *SlicePool[E] = &SlicePool[E]{
+		slots:   make([]pslot, len(ct.sizes)),
+		counts:  make([]pcount, len(ct.sizes)),
+		classes: ct,
+		opts:    o,
+	}
+	for i := range sp.slots {
+		sp.slots[i].seg = uint32(o.SegmentSize)
+		sp.slots[i].entry = unsafe.Pointer(newlfsliceSize(sp.slots[i].seg))
+	}
+	if o.Stats {
+		sp.stats = &Stats{blocks: make([]stat, len(ct.sizes)), sizes: ct.sizes}
+	}
+	return sp, nil
+}
+
+// Classes returns the capacities, in elements,
+// of the classes served by the pool.
+func (sp *SlicePool[E]) Classes() []int {
+	return sp.classes.active()
+}
+
+// Get returns a slice of length `n` whose
+// capacity is the class serving `n`, or
+// exactly `n` above `MaxSize`.
+func (sp *SlicePool[E]) Get(n int) []E {
+	if n < 0 {
+		panic("core(pool): negative length")
+	}
+	if n > sp.opts.MaxSize {
+		if sp.opts.Oversize == OversizeError {
+			panic("core(pool): size above the largest class")
+		}
+		if sp.stats != nil {
+			atomic.AddUint64(&sp.stats.huge.allocs, 1)
+		}
+		return make([]E, n)
+	}
+	var (
+		index int = sp.classes.index(n)
+		size  int = sp.classes.size(index)
+	)
+	if v := (*lfslice)(sp.slots[index].ldEntry()).pop(); v != nil {
+		atomic.AddInt64(&sp.counts[index].count, -1)
+		atomic.AddInt64(&sp.retained, -sp.bytes(size))
+		if sp.stats != nil {
+			atomic.AddUint64(&sp.stats.blocks[index].hits, 1)
+		}
+		ret := unsafe.Slice((*E)(v), size)
+		if sp.opts.Zero == ZeroOnGet {
+			clear(ret)
+		}
+		return ret[:n]
+	}
+	if sp.stats != nil {
+		atomic.AddUint64(&sp.stats.blocks[index].allocs, 1)
+	}
+	return make([]E, n, size)
+}
+
+// Put retains `s` in the largest class its
+// capacity holds. Slices below `MinSize` or
+// above `MaxSize` and releases beyond
+// `MaxRetained` are left to the GC.
+func (sp *SlicePool[E]) Put(s []E) {
+	var (
+		capacity int = cap(s)
+		index    int
+	)
+	if capacity < sp.opts.MinSize {
+		return
+	}
+	if capacity > sp.opts.MaxSize {
+		if sp.stats != nil {
+			atomic.AddUint64(&sp.stats.huge.rels, 1)
+			atomic.AddUint64(&sp.stats.huge.deallocs, 1)
+		}
+		return
+	}
+	index = sp.classes.index(capacity)
+	if sp.classes.size(index) > capacity {
+		index--
+		if sp.stats != nil {
+			atomic.AddUint64(&sp.stats.blocks[index].rounds, 1)
+		}
+	}
+	if sp.stats != nil {
+		atomic.AddUint64(&sp.stats.blocks[index].rels, 1)
+	}
+	ps := &sp.counts[index]
+	n := atomic.AddInt64(&ps.count, 1)
+	if sp.opts.MaxRetained > 0 && n > int64(sp.opts.MaxRetained) {
+		atomic.AddInt64(&ps.count, -1)
+		if sp.stats != nil {
+			atomic.AddUint64(&sp.stats.blocks[index].deallocs, 1)
+		}
+		return
+	}
+	s = s[:sp.classes.size(index)]
+	if sp.opts.Zero == ZeroOnRelease {
+		clear(s)
+	}
+	bytes := atomic.AddInt64(&sp.retained, sp.bytes(len(s)))
+	(*lfslice)(sp.slots[index].ldEntry()).push(unsafe.Pointer(unsafe.SliceData(s)))
+	if sp.stats != nil {
+		peak(&sp.stats.blocks[index].max, n)
+		peak(&sp.stats.peak, bytes)
+	}
+}
+
+// Stats returns a snapshot of the pool, see
+// `LFPool.Stats`. Class sizes count elements,
+// byte counts are in bytes.
+func (sp *SlicePool[E]) Stats() Snapshot {
+	var ret Snapshot = Snapshot{
+		Classes:       make([]ClassStats, 0, sp.classes.hi-sp.classes.lo+1),
+		RetainedBytes: atomic.LoadInt64(&sp.retained),
+	}
+	for index := sp.classes.lo; index <= sp.classes.hi; index++ {
+		cs := ClassStats{
+			Size:     sp.classes.size(index),
+			Retained: atomic.LoadInt64(&sp.counts[index].count),
+		}
+		cs.RetainedBytes = cs.Retained * sp.bytes(cs.Size)
+		if sp.stats != nil {
+			sp.stats.blocks[index].load(&cs)
+		}
+		ret.Classes = append(ret.Classes, cs)
+	}
+	if sp.stats != nil {
+		sp.stats.huge.load(&ret.Oversize)
+		ret.PeakBytes = int64(atomic.LoadUint64(&sp.stats.peak))
+	}
+	return ret
+}
+
+// ResetStats zeroes the counters of the pool,
+// see `LFPool.ResetStats`.
+func (sp *SlicePool[E]) ResetStats() {
+	if sp.stats == nil {
+		return
+	}
+	for index := range sp.stats.blocks {
+		sp.stats.blocks[index].reset(atomic.LoadInt64(&sp.counts[index].count))
+	}
+	sp.stats.huge.reset(0)
+	atomic.StoreUint64(&sp.stats.peak, uint64(atomic.LoadInt64(&sp.retained)))
+}
+
+// bytes returns the size of `n` elements.
+func (sp *SlicePool[E]) bytes(n int) int64 {
+	return int64(n) * int64(unsafe.Sizeof(*new(E)))
+}
+
+// GetVec returns an empty `Vec` with room for
+// at least `n` elements.
+func (sp *SlicePool[E]) GetVec(n int) *Vec[E] {
+	return &Vec[E]{sp.Get(n)[:0], sp}
+}
+
+// - MARK: Vec section.
+
+// Append appends `elems`, growing `v` first
+// when needed.
+func (v *Vec[E]) Append(elems ...E) {
+	v.Grow(len(elems))
+	v.Data = append(v.Data, elems...)
+}
+
+// Grow makes room for `n` more elements. The
+// capacity at least doubles so that appends
+// stay amortized constant time.
+func (v *Vec[E]) Grow(n int) {
+	var (
+		length int = len(v.Data)
+		want   int = length + n
+	)
+	if want <= cap(v.Data) {
+		return
+	}
+	if want < 2*cap(v.Data) {
+		want = 2 * cap(v.Data)
+	}
+	if v.mp == nil {
+		v.Data = append(make([]E, 0, want), v.Data...)
+		return
+	}
+	data := v.mp.Get(want)[:length]
+	copy(data, v.Data)
+	v.mp.Put(v.Data)
+	v.Data = data
+}
+
+func (v *Vec[E]) Len() int {
+	return len(v.Data)
+}
+
+func (v *Vec[E]) Reset() {
+	v.Data = v.Data[:0]
+}
+
+// Release puts the backing array back, `v`
+// must not be used afterwards.
+func (v *Vec[E]) Release() {
+	if v.mp != nil {
+		v.mp.Put(v.Data)
+		v.mp = nil
+		v.Data = nil
+	}
+}
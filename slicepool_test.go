@@ -0,0 +1,98 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"testing"
+)
+
+func TestSlicePool(t *testing.T) {
+	sp, err := NewSlicePool[uint32](WithMinSize(16), WithMaxSize(1024), WithStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sp.Get(100)
+	if len(s) != 100 || cap(s) != 128 {
+		t.Fatal("invalid slice", len(s), cap(s))
+	}
+	s[0] = 7
+	sp.Put(s)
+	if c := sp.Get(65); &c[0] != &s[0] || len(c) != 65 || cap(c) != 128 || c[0] != 7 {
+		t.Fatal("expected reuse")
+	}
+	m := make([]uint32, 0, 100)
+	sp.Put(m)
+	if c := sp.Get(64); &c[:1][0] != &m[:1][0] || cap(c) != 64 {
+		t.Fatal("expected the rounded down slice")
+	}
+	if c := sp.Get(2000); cap(c) != 2000 {
+		t.Fatal("invalid oversized slice", cap(c))
+	}
+	var cs ClassStats
+	for _, c := range sp.Stats().Classes {
+		if c.Size == 128 {
+			cs = c
+		}
+	}
+	if cs.Misses != 1 || cs.Hits != 1 || cs.Releases != 1 || cs.PeakRetained != 1 || cs.Retained != 0 {
+		t.Fatal("invalid class stats", cs)
+	}
+	if s := sp.Stats(); s.Oversize.Misses != 1 || s.RetainedBytes != 0 || s.PeakBytes != 128*4 {
+		t.Fatal("invalid stats", s.Oversize, s.RetainedBytes, s.PeakBytes)
+	}
+	sp.ResetStats()
+	if s := sp.Stats(); s.Oversize.Misses != 0 || s.PeakBytes != 0 {
+		t.Fatal("stats not reset", s.Oversize, s.PeakBytes)
+	}
+
+	zp, _ := NewSlicePool[*int](WithZero(ZeroOnRelease))
+	p := zp.Get(1)
+	p[0] = new(int)
+	zp.Put(p)
+	if q := zp.Get(1); q[0] != nil {
+		t.Fatal("retained a pointer")
+	}
+}
+
+func TestVec(t *testing.T) {
+	sp, _ := NewSlicePool[float64](WithMinSize(16), WithMaxSize(1024))
+	v := sp.GetVec(10)
+	if v.Len() != 0 || cap(v.Data) != 16 {
+		t.Fatal("invalid vec", v.Len(), cap(v.Data))
+	}
+	first := &v.Data[:1][0]
+	for i := 0; i < 100; i++ {
+		v.Append(float64(i))
+	}
+	if v.Len() != 100 || cap(v.Data) != 128 || v.Data[99] != 99 {
+		t.Fatal("invalid vec", v.Len(), cap(v.Data))
+	}
+	if c := sp.Get(16); &c[0] != first {
+		t.Fatal("expected the outgrown array back in the pool")
+	}
+	data := &v.Data[0]
+	v.Release()
+	if c := sp.Get(100); &c[0] != data {
+		t.Fatal("expected reuse of the released array")
+	}
+}
@@ -0,0 +1,238 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// - MARK: Snapshot section.
+
+// Snapshot is a point in time copy of the
+// statistics of a pool. Counters are zero
+// unless `Stats` is set. Counters are read
+// one by one, a snapshot taken under load
+// is not atomic as a whole. The pools behind
+// `GetAligned` are folded in by class, their
+// peaks are added up, which bounds the peak
+// of the sum from above.
+type Snapshot struct {
+	Classes       []ClassStats `json:"classes"`
+	Oversize      ClassStats   `json:"oversize"`
+	RetainedBytes int64        `json:"retained_bytes"`
+	PeakBytes     int64        `json:"peak_retained_bytes"`
+}
+
+// ClassStats holds the statistics of one
+// class, or of the oversized buffers.
+type ClassStats struct {
+	// Size is the capacity of the class, zero
+	// for oversized buffers.
+	Size int `json:"size"`
+	// Gets is the number of buffers handed
+	// out, `Hits` plus `Misses`.
+	Gets uint64 `json:"gets"`
+	// Hits are gets served by a pooled buffer.
+	Hits uint64 `json:"hits"`
+	// Misses are gets served by a new buffer.
+	Misses uint64 `json:"misses"`
+	// Releases are buffers taken back.
+	Releases uint64 `json:"releases"`
+	// Drops are buffers discarded by caps,
+	// trimming, shrinking or draining.
+	Drops uint64 `json:"drops"`
+	// Slabs is the number of slabs carved.
+	Slabs uint64 `json:"slabs"`
+	// Copies, Rounds and Discards count the
+	// releases handled by `Misfit`.
+	Copies   uint64 `json:"copies"`
+	Rounds   uint64 `json:"rounds"`
+	Discards uint64 `json:"discards"`
+	// Retained is the number of buffers in
+	// the pool, local caches included.
+	Retained int64 `json:"retained"`
+	// RetainedBytes is their capacity.
+	RetainedBytes int64 `json:"retained_bytes"`
+	// PeakRetained is the largest `Retained`
+	// since creation or `ResetStats`.
+	PeakRetained int64 `json:"peak_retained"`
+}
+
+// Metric is a named value of a `Snapshot`.
+// Names follow `runtime/metrics`, a path
+// followed by a unit, e.g.
+// `/lfpool/class/1024/hits:calls`.
+type Metric struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
+
+// Stats returns a snapshot of the statistics
+// of the pool.
+func (lfp *LFPool) Stats() Snapshot {
+	var ret Snapshot = lfp.snapshot()
+	lfp.eachAligned(func(child *LFPool) {
+		ret.add(child.snapshot())
+	})
+	return ret
+}
+
+// snapshot is `Stats` without the aligned
+// pools.
+func (lfp *LFPool) snapshot() Snapshot {
+	var ret Snapshot = Snapshot{
+		Classes:       make([]ClassStats, 0, lfp.classes.hi-lfp.classes.lo+1),
+		RetainedBytes: atomic.LoadInt64(&lfp.retained),
+	}
+	for index := lfp.classes.lo; index <= lfp.classes.hi; index++ {
+		cs := ClassStats{Size: lfp.classes.size(index)}
+		if lfp.track {
+			cs.Retained = atomic.LoadInt64(&lfp.counts[index].count)
+			cs.RetainedBytes = cs.Retained * int64(cs.Size)
+		}
+		if lfp.stats != nil {
+			lfp.stats.blocks[index].load(&cs)
+		}
+		ret.Classes = append(ret.Classes, cs)
+	}
+	ret.Oversize.Retained, ret.Oversize.RetainedBytes = lfp.huge.size()
+	if lfp.stats != nil {
+		lfp.stats.huge.load(&ret.Oversize)
+		ret.PeakBytes = int64(atomic.LoadUint64(&lfp.stats.peak))
+	}
+	return ret
+}
+
+// ResetStats zeroes the counters of the pool
+// and restarts the peaks from the current
+// retained buffers.
+func (lfp *LFPool) ResetStats() {
+	if lfp.stats == nil {
+		return
+	}
+	for index := range lfp.stats.blocks {
+		lfp.stats.blocks[index].reset(atomic.LoadInt64(&lfp.counts[index].count))
+	}
+	n, _ := lfp.huge.size()
+	lfp.stats.huge.reset(n)
+	atomic.StoreUint64(&lfp.stats.peak, uint64(atomic.LoadInt64(&lfp.retained)))
+	lfp.eachAligned(func(child *LFPool) {
+		child.ResetStats()
+	})
+}
+
+// add folds `o` into `s`, class by class.
+func (s *Snapshot) add(o Snapshot) {
+	for _, cs := range o.Classes {
+		i := sort.Search(len(s.Classes), func(i int) bool { return s.Classes[i].Size >= cs.Size })
+		if i == len(s.Classes) || s.Classes[i].Size != cs.Size {
+			s.Classes = append(s.Classes, ClassStats{})
+			copy(s.Classes[i+1:], s.Classes[i:])
+			s.Classes[i] = ClassStats{Size: cs.Size}
+		}
+		s.Classes[i].add(cs)
+	}
+	s.Oversize.add(o.Oversize)
+	s.RetainedBytes += o.RetainedBytes
+	s.PeakBytes += o.PeakBytes
+}
+
+// add folds the counters of `o` into `cs`.
+func (cs *ClassStats) add(o ClassStats) {
+	cs.Gets += o.Gets
+	cs.Hits += o.Hits
+	cs.Misses += o.Misses
+	cs.Releases += o.Releases
+	cs.Drops += o.Drops
+	cs.Slabs += o.Slabs
+	cs.Copies += o.Copies
+	cs.Rounds += o.Rounds
+	cs.Discards += o.Discards
+	cs.Retained += o.Retained
+	cs.RetainedBytes += o.RetainedBytes
+	cs.PeakRetained += o.PeakRetained
+}
+
+// Metrics lists the values of `s` by name.
+func (s Snapshot) Metrics() []Metric {
+	var ret []Metric = []Metric{
+		{"/lfpool/retained:bytes", s.RetainedBytes},
+		{"/lfpool/retained/peak:bytes", s.PeakBytes},
+	}
+	ret = s.Oversize.metrics(ret, "/lfpool/oversize")
+	for _, cs := range s.Classes {
+		ret = cs.metrics(ret, fmt.Sprintf("/lfpool/class/%d", cs.Size))
+	}
+	return ret
+}
+
+func (cs ClassStats) metrics(ret []Metric, prefix string) []Metric {
+	return append(ret,
+		Metric{prefix + "/gets:calls", int64(cs.Gets)},
+		Metric{prefix + "/hits:calls", int64(cs.Hits)},
+		Metric{prefix + "/misses:calls", int64(cs.Misses)},
+		Metric{prefix + "/releases:calls", int64(cs.Releases)},
+		Metric{prefix + "/drops:buffers", int64(cs.Drops)},
+		Metric{prefix + "/slabs:slabs", int64(cs.Slabs)},
+		Metric{prefix + "/misfit/copies:calls", int64(cs.Copies)},
+		Metric{prefix + "/misfit/rounds:calls", int64(cs.Rounds)},
+		Metric{prefix + "/misfit/discards:calls", int64(cs.Discards)},
+		Metric{prefix + "/retained:buffers", cs.Retained},
+		Metric{prefix + "/retained:bytes", cs.RetainedBytes},
+		Metric{prefix + "/retained/peak:buffers", cs.PeakRetained},
+	)
+}
+
+// load copies the counters of `s` to `cs`.
+func (s *stat) load(cs *ClassStats) {
+	cs.Hits = atomic.LoadUint64(&s.hits)
+	cs.Misses = atomic.LoadUint64(&s.allocs)
+	cs.Gets = cs.Hits + cs.Misses
+	cs.Releases = atomic.LoadUint64(&s.rels)
+	cs.Drops = atomic.LoadUint64(&s.deallocs)
+	cs.Slabs = atomic.LoadUint64(&s.slabs)
+	cs.Copies = atomic.LoadUint64(&s.copies)
+	cs.Rounds = atomic.LoadUint64(&s.rounds)
+	cs.Discards = atomic.LoadUint64(&s.discards)
+	cs.PeakRetained = int64(atomic.LoadUint64(&s.max))
+}
+
+// reset zeroes the counters of `s`, its peak
+// restarts from `retained`.
+func (s *stat) reset(retained int64) {
+	for _, counter := range []*uint64{&s.allocs, &s.hits, &s.rels, &s.deallocs, &s.slabs, &s.copies, &s.rounds, &s.discards} {
+		atomic.StoreUint64(counter, 0)
+	}
+	atomic.StoreUint64(&s.max, uint64(retained))
+}
+
+// peak raises `addr` to `n`.
+func peak(addr *uint64, n int64) {
+	for old := atomic.LoadUint64(addr); uint64(n) > old; old = atomic.LoadUint64(addr) {
+		if atomic.CompareAndSwapUint64(addr, old, uint64(n)) {
+			return
+		}
+	}
+}
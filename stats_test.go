@@ -0,0 +1,135 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStatsSnapshot(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithStats(true), WithLocalCacheSize(0), WithMaxRetained(1), WithMaxSize(1<<20), WithOversize(OversizeAlloc, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := bp.Get(100), bp.Get(100)
+	bp.Release(a)
+	bp.Release(b)
+	bp.Release(bp.Get(100))
+	bp.Release(bp.Get(2 << 20))
+	s := bp.Stats()
+	if len(s.Classes) != len(bp.Classes()) || s.Classes[1].Size != 128 {
+		t.Fatal("invalid classes", len(s.Classes))
+	}
+	want := ClassStats{
+		Size:          128,
+		Gets:          3,
+		Hits:          1,
+		Misses:        2,
+		Releases:      3,
+		Drops:         1,
+		Retained:      1,
+		RetainedBytes: 128,
+		PeakRetained:  1,
+	}
+	if s.Classes[1] != want {
+		t.Fatalf("invalid class stats %+v", s.Classes[1])
+	}
+	if o := s.Oversize; o.Misses != 1 || o.Releases != 1 || o.Retained != 1 || o.RetainedBytes != 2<<20 {
+		t.Fatalf("invalid oversize stats %+v", o)
+	}
+	if s.RetainedBytes != 128 || s.PeakBytes != 128 {
+		t.Fatal("invalid retained bytes", s.RetainedBytes, s.PeakBytes)
+	}
+	data, err := json.Marshal(s)
+	if err != nil || !strings.Contains(string(data), `"size":128,"gets":3,"hits":1`) {
+		t.Fatal("invalid json", err, string(data))
+	}
+	var found bool
+	for _, m := range s.Metrics() {
+		if m.Name == "/lfpool/class/128/hits:calls" {
+			found = m.Value == 1
+		}
+	}
+	if !found {
+		t.Fatal("missing metric")
+	}
+	bp.ResetStats()
+	if c := bp.Stats().Classes[1]; c.Gets != 0 || c.Drops != 0 || c.Retained != 1 || c.PeakRetained != 1 {
+		t.Fatalf("invalid reset stats %+v", c)
+	}
+}
+
+func TestStatsAligned(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithStats(true), WithLocalCacheSize(0), WithAuto(true, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp.ReleaseAligned(bp.GetAligned(100, 64), 64)
+	b, err := bp.AutoGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp.AutoRelease(b)
+	var cs, auto ClassStats
+	for _, c := range bp.Stats().Classes {
+		switch c.Size {
+		case 128:
+			cs = c
+		case cap(b):
+			auto = c
+		}
+	}
+	if cs.Misses != 1 || cs.Releases != 1 || cs.Retained != 1 || cs.RetainedBytes != 128 {
+		t.Fatalf("aligned pool missing from stats %+v", cs)
+	}
+	if auto.Releases != 1 {
+		t.Fatalf("auto release not counted %+v", auto)
+	}
+	if s := bp.Stats(); s.RetainedBytes != int64(128+cap(b)) {
+		t.Fatal("invalid retained bytes", s.RetainedBytes)
+	}
+	bp.ResetStats()
+	for _, c := range bp.Stats().Classes {
+		if c.Gets != 0 || c.Releases != 0 {
+			t.Fatalf("stats not reset %+v", c)
+		}
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	p, _ := NewPool(func() *[64]byte { return new([64]byte) }, nil, WithStats(true))
+	x, y := p.Get(), p.Get()
+	p.Put(x)
+	p.Put(y)
+	p.Get()
+	s := p.Stats()
+	if s.Size != 64 || s.Gets != 3 || s.Hits != 1 || s.Releases != 2 || s.Retained != 1 || s.PeakRetained != 2 {
+		t.Fatalf("invalid stats %+v", s)
+	}
+	p.ResetStats()
+	if s := p.Stats(); s.Gets != 0 || s.PeakRetained != 1 {
+		t.Fatalf("invalid reset stats %+v", s)
+	}
+}
@@ -0,0 +1,249 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// - MARK: janitor section.
+
+// janitor runs the periodic passes of a pool
+// on its own goroutine, it backs both
+// `LFPool` and `Pool`.
+type janitor struct {
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// start runs `tick` every `interval` until
+// `halt`, it is a no-op when already running.
+func (j *janitor) start(interval time.Duration, tick func(now int64)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.stop != nil {
+		return
+	}
+	j.stop, j.done = make(chan struct{}), make(chan struct{})
+	go j.run(interval, tick, j.stop, j.done)
+}
+
+// halt stops the janitor and waits for it
+// to exit.
+func (j *janitor) halt() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.stop == nil {
+		return
+	}
+	close(j.stop)
+	<-j.done
+	j.stop, j.done = nil, nil
+}
+
+func (j *janitor) run(interval time.Duration, tick func(now int64), stop chan struct{}, done chan struct{}) {
+	var ticker *time.Ticker = time.NewTicker(interval)
+	defer close(done)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			tick(now.UnixNano())
+		}
+	}
+}
+
+// enter accounts one more retained entry, it
+// fails beyond `max` when `max` is positive.
+func (pc *pcount) enter(max int64) (int64, bool) {
+	count := atomic.AddInt64(&pc.count, 1)
+	if max > 0 && count > max {
+		atomic.AddInt64(&pc.count, -1)
+		return 0, false
+	}
+	return count, true
+}
+
+// leave accounts `n` entries leaving, the low
+// watermark follows when `low` is set.
+func (pc *pcount) leave(n int64, low bool) {
+	var count int64 = atomic.AddInt64(&pc.count, -n)
+	if !low {
+		return
+	}
+	for l := atomic.LoadInt64(&pc.low); count < l; l = atomic.LoadInt64(&pc.low) {
+		if atomic.CompareAndSwapInt64(&pc.low, l, count) {
+			break
+		}
+	}
+}
+
+// due reports whether the idle window of `pc`
+// elapsed at `now`.
+func (pc *pcount) due(now int64, idle int64) bool {
+	return now-atomic.LoadInt64(&pc.since) >= idle
+}
+
+// rearm starts a new idle window at `now`.
+func (pc *pcount) rearm(now int64) {
+	atomic.StoreInt64(&pc.low, atomic.LoadInt64(&pc.count))
+	atomic.StoreInt64(&pc.since, now)
+}
+
+// watermark returns the low watermark of `pc`
+// and whether it covers every retained entry,
+// i.e. none was reused during the window.
+func (pc *pcount) watermark() (int64, bool) {
+	var low int64 = atomic.LoadInt64(&pc.low)
+	return low, low > 0 && low >= atomic.LoadInt64(&pc.count)
+}
+
+// Start launches the janitor, which trims
+// idle buffers every `TrimInterval` and
+// watches memory limits. It returns
+// `LPNotSupported` when both are disabled
+// and `ErrClosed` after `Close`.
+func (lfp *LFPool) Start() error {
+	var interval time.Duration = lfp.opts.TrimInterval
+	if interval <= 0 && !lfp.opts.watched() {
+		return LPNotSupported
+	}
+	if interval <= 0 {
+		interval = cWatchInterval
+	}
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		return ErrClosed
+	}
+	lfp.jan.start(interval, lfp.tick)
+	// NOTE
+	// . a `Close` racing the start either
+	//   stops the janitor or is seen here.
+	if atomic.LoadUint32(&lfp.closed) != 0 {
+		lfp.jan.halt()
+		return ErrClosed
+	}
+	return nil
+}
+
+// Stop stops the janitor and waits for it
+// to exit.
+func (lfp *LFPool) Stop() {
+	lfp.jan.halt()
+}
+
+// Trim drops every buffer that was not reused
+// since the previous pass and returns the
+// freed bytes. It is a no-op when trimming
+// is disabled.
+func (lfp *LFPool) Trim() int64 {
+	if lfp.opts.TrimInterval <= 0 {
+		return 0
+	}
+	return lfp.trim(time.Now().UnixNano(), true)
+}
+
+// tick is one pass of the janitor.
+func (lfp *LFPool) tick(now int64) {
+	var freed int64
+	if lfp.opts.TrimInterval > 0 {
+		freed += lfp.trim(now, false)
+	}
+	if lfp.opts.watched() {
+		freed += lfp.watchMemory()
+	}
+	if lfp.opts.OnTrim != nil {
+		lfp.opts.OnTrim(freed)
+	}
+}
+
+// trim runs one pass over every class whose
+// idle window elapsed, or all of them when
+// `force` is set.
+func (lfp *LFPool) trim(now int64, force bool) int64 {
+	var (
+		freed int64
+		idle  int64 = lfp.opts.idle()
+	)
+	defer endRegion(lfp.region("lfpool.trim"))
+	for index := lfp.classes.lo; index <= lfp.classes.hi; index++ {
+		ps := &lfp.counts[index]
+		if !force && !ps.due(now, idle) {
+			continue
+		}
+		n, all := lfp.trimClass(index)
+		if all {
+			lfp.detached(index, n)
+		} else if n > 0 && lfp.opts.Observer != nil {
+			lfp.opts.Observer.Trim(lfp.class(index), lfp.classes.size(index), n)
+		}
+		freed += n * int64(lfp.classes.size(index))
+		ps.rearm(now)
+	}
+	freed += lfp.flushHuge(force)
+	lfp.eachAligned(func(child *LFPool) {
+		freed += child.trim(now, force)
+	})
+	return freed
+}
+
+// trimClass drops the buffers of class `index`
+// that stayed in the pool for the whole idle
+// window, i.e. its low watermark. A class that
+// was not reused at all is detached at once,
+// which is reported along.
+func (lfp *LFPool) trimClass(index int) (int64, bool) {
+	low, all := lfp.counts[index].watermark()
+	if low <= 0 {
+		return 0, false
+	}
+	if all {
+		return lfp.cleanUp(index, lfp.slots[index].detach()) + lfp.flushLocal(index, -1), true
+	}
+	n := lfp.entry(index).drain(low, lfp.discarder(index))
+	if n > 0 {
+		lfp.unreserve(index, n)
+	}
+	if n < low {
+		n += lfp.flushLocal(index, low-n)
+	}
+	return n, false
+}
+
+// flushLocal drops up to `max` buffers of
+// class `index` from the local caches, or
+// all of them when `max` is negative. The
+// caller reports them to the observer.
+func (lfp *LFPool) flushLocal(index int, max int64) int64 {
+	n := lfp.drainLocal(index, max, func(chunk []byte) {
+		lfp.discard(index, chunk)
+	})
+	if n > 0 && lfp.track {
+		lfp.unreserve(index, n)
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrim(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithTrim(time.Hour, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	held := make([][]byte, 0, 10)
+	for i := 0; i < 10; i++ {
+		held = append(held, bp.Get(1024))
+	}
+	for _, b := range held {
+		bp.Release(b)
+	}
+	if n := bp.Trim(); n != 0 {
+		t.Fatal("first pass must only arm the window", n)
+	}
+	for i := 0; i < 3; i++ {
+		bp.Release(bp.Get(1024))
+	}
+	bp.Get(1024)
+	bp.Get(1024)
+	if n := bp.Trim(); n != 8*1024 {
+		t.Fatal("invalid freed bytes", n)
+	}
+	if n := bp.retained; n != 0 {
+		t.Fatal("invalid retained bytes", n)
+	}
+	if n := NewLFPool().Trim(); n != 0 {
+		t.Fatal("trimming must be disabled", n)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	freed := make(chan int64, 16)
+	bp, err := NewLFPoolWithOptions(
+		WithTrim(5*time.Millisecond, 0),
+		WithOnTrim(func(n int64) {
+			if n > 0 {
+				select {
+				case freed <- n:
+				default:
+				}
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		bp.Release(make([]byte, 4096))
+	}
+	if err := bp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer bp.Stop()
+	select {
+	case n := <-freed:
+		if n != 4*4096 {
+			t.Fatal("invalid freed bytes", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("janitor did not trim")
+	}
+	if err := NewLFPool().Start(); err != LPNotSupported {
+		t.Fatal("expected error", err)
+	}
+}
@@ -0,0 +1,113 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+	"weak"
+)
+
+// - MARK: view section.
+
+// viewRec locates the buffer of a view. It
+// holds no pointer, the buffer is rebuilt out
+// of the released sub-slice that keeps it
+// alive.
+type viewRec struct {
+	base    uintptr
+	size    int
+	cleanup runtime.Cleanup
+}
+
+// viewRef is the argument of the cleanup
+// deleting the entry of a view that is never
+// released.
+type viewRef struct {
+	pool weak.Pointer[LFPool]
+	end  uintptr
+	rec  *viewRec
+}
+
+// view returns `chunk[:n:n]` and registers
+// `chunk` under the end address of the view,
+// which every sub-slice that reaches the end
+// of the view shares. Views of the whole
+// buffer are not registered, nor are views of
+// a closed pool. An empty view holds no
+// buffer, `chunk` goes back to the pool.
+func (lfp *LFPool) view(chunk []byte, n int) []byte {
+	if n == 0 {
+		lfp.release(chunk)
+		return chunk[:0:0]
+	}
+	if n == cap(chunk) {
+		return chunk[:n]
+	}
+	ret := chunk[:n:n]
+	if atomic.LoadUint32(&lfp.closed) == 0 {
+		var (
+			end uintptr  = viewEnd(ret)
+			rec *viewRec = &viewRec{base: uintptr(unsafe.Pointer(&chunk[0])), size: cap(chunk)}
+		)
+		if _, ok := lfp.mmaps.Load(rec.base); !ok {
+			rec.cleanup = runtime.AddCleanup(&chunk[0], forgetView, viewRef{weak.Make(lfp), end, rec})
+		}
+		lfp.views.Store(end, rec)
+	}
+	return ret
+}
+
+// unview returns the buffer `chunk` is a view
+// of, or `chunk` itself when it is not one.
+func (lfp *LFPool) unview(chunk []byte) []byte {
+	if cap(chunk) == 0 {
+		return chunk
+	}
+	v, ok := lfp.views.LoadAndDelete(viewEnd(chunk))
+	if !ok {
+		return chunk
+	}
+	var (
+		rec  *viewRec       = v.(*viewRec)
+		data unsafe.Pointer = unsafe.Pointer(unsafe.SliceData(chunk))
+	)
+	rec.cleanup.Stop()
+	return unsafe.Slice((*byte)(unsafe.Add(data, -int(uintptr(data)-rec.base))), rec.size)
+}
+
+// forgetView deletes the entry of a view whose
+// buffer was collected, unless the address
+// went to a new view meanwhile.
+func forgetView(r viewRef) {
+	if lfp := r.pool.Value(); lfp != nil {
+		lfp.views.CompareAndDelete(r.end, r.rec)
+	}
+}
+
+// viewEnd returns the address past the last
+// byte of the capacity of `chunk`.
+func viewEnd(chunk []byte) uintptr {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(chunk))) + uintptr(cap(chunk))
+}
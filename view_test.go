@@ -0,0 +1,114 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestExactCap(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithExactCap(true), WithOwnership(true), WithDebug(false), WithLocalCacheSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.Get(100)
+	if len(b) != 100 || cap(b) != 100 {
+		t.Fatal("invalid view", len(b), cap(b))
+	}
+	base := &b[0]
+	bp.Release(b)
+	c := bp.Get(120)
+	if &c[0] != base || cap(c) != 120 {
+		t.Fatal("expected reuse of the class buffer")
+	}
+	if err := bp.TryRelease(c[30:60]); err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.TryRelease(c); !errors.Is(err, ErrDoubleRelease) {
+		t.Fatal("expected ErrDoubleRelease", err)
+	}
+	if c := bp.Get(10, 128); &c[0] != base || len(c) != 10 || cap(c) != 128 {
+		t.Fatal("expected the whole class buffer", len(c), cap(c))
+	}
+	d, err := bp.TryGet(5, 70)
+	if err != nil || len(d) != 5 || cap(d) != 70 {
+		t.Fatal("invalid view", err, len(d), cap(d))
+	}
+	if err := bp.TryRelease(d[70:70]); err != nil {
+		t.Fatal("empty slice not ignored", err)
+	}
+	if err := bp.TryRelease(d[69:70]); err != nil {
+		t.Fatal(err)
+	}
+	if e := bp.Get(65); &e[0] != &d[0] {
+		t.Fatal("expected reuse of the class buffer")
+	}
+}
+
+func TestExactCapEmpty(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithExactCap(true), WithOwnership(true), WithDebug(true), WithLocalCacheSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bp.Get(64)
+	base := &b[0]
+	bp.Release(b)
+	e := bp.Get(0)
+	if e == nil || len(e) != 0 || cap(e) != 0 {
+		t.Fatal("invalid empty view", len(e), cap(e))
+	}
+	bp.Release(e)
+	if err := bp.TryRelease(e); err != nil {
+		t.Fatal("empty view not ignored", err)
+	}
+	if c := bp.Get(50); &c[0] != base {
+		t.Fatal("empty view kept the class buffer")
+	}
+}
+
+func TestExactCapAbandoned(t *testing.T) {
+	bp, err := NewLFPoolWithOptions(WithExactCap(true), WithLocalCacheSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp.Get(100)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := 0
+		bp.views.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("view of a collected buffer kept registered")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
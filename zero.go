@@ -0,0 +1,63 @@
+/* MIT License
+*
+* Copyright (c) 2018 Mike Taghavi <mitghi[at]gmail.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+ */
+
+package lfpool
+
+import (
+	"unsafe"
+)
+
+// - MARK: zero section.
+
+// ZeroPolicy tells when pooled buffers are
+// cleared.
+type ZeroPolicy int
+
+const (
+	// ZeroNone hands out buffers with the
+	// contents left by their previous user.
+	ZeroNone ZeroPolicy = iota
+	// ZeroOnRelease clears buffers as they
+	// are retained, pooled memory never holds
+	// stale data.
+	ZeroOnRelease
+	// ZeroOnGet clears buffers as they are
+	// handed out.
+	ZeroOnGet
+)
+
+const (
+	cZeroAdvise = 1 << 20
+)
+
+// zero clears the whole capacity of `chunk`.
+// Large mappings are dropped with madvise
+// instead of being written.
+func (lfp *LFPool) zero(chunk []byte) {
+	chunk = chunk[:cap(chunk)]
+	if len(chunk) >= cZeroAdvise && lfp.opts.OffHeapSize > 0 {
+		if _, ok := lfp.mmaps.Load(uintptr(unsafe.Pointer(&chunk[0]))); ok && adviseZero(chunk) {
+			return
+		}
+	}
+	clear(chunk)
+}
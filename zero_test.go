@@ -0,0 +1,70 @@
+/**
+* MIT License
+*
+* Copyright (c) 2017 Mike Taghavi <mitghi@me.com>
+*
+* Permission is hereby granted, free of charge, to any person obtaining a copy
+* of this software and associated documentation files (the "Software"), to deal
+* in the Software without restriction, including without limitation the rights
+* to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+* copies of the Software, and to permit persons to whom the Software is
+* furnished to do so, subject to the following conditions:
+*
+* The above copyright notice and this permission notice shall be included in all
+* copies or substantial portions of the Software.
+*
+* THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+* IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+* FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+* AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+* LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+* OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+* SOFTWARE.
+**/
+
+package lfpool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestZero(t *testing.T) {
+	dirty := func(b []byte) []byte {
+		b = b[:cap(b)]
+		for i := range b {
+			b[i] = 0xff
+		}
+		return b
+	}
+	clean := func(b []byte) bool {
+		return bytes.Count(b[:cap(b)], []byte{0}) == cap(b)
+	}
+	for _, policy := range []ZeroPolicy{ZeroNone, ZeroOnRelease, ZeroOnGet} {
+		bp, err := NewLFPoolWithOptions(WithZero(policy), WithDebug(false), WithLocalCacheSize(0), WithOffHeap(1<<20, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []int{1000, 2 << 20} {
+			b := dirty(bp.Get(size))
+			bp.Release(b)
+			if policy == ZeroOnRelease && !clean(b) {
+				t.Fatal("buffer not cleared on release", size)
+			}
+			c := bp.Get(size)
+			if &c[0] != &b[0] {
+				t.Fatal("expected reuse")
+			}
+			if clean(c) != (policy != ZeroNone) {
+				t.Fatal("invalid buffer contents", policy, size)
+			}
+			bp.Release(dirty(c))
+			if c := bp.GetZeroed(size); !clean(c) {
+				t.Fatal("GetZeroed returned a dirty buffer", policy, size)
+			}
+		}
+	}
+	if _, err := NewLFPoolWithOptions(WithZero(ZeroPolicy(7))); err == nil {
+		t.Fatal("expected error")
+	}
+}